@@ -11,8 +11,8 @@
 //   - diff nicely in git history and code reviews.
 //
 // Non-goals include being a completely general archive format,
-// storing binary data, storing file modes, storing special files like
-// symbolic links, and so on.
+// storing special files like devices, and so on. Small binary files are supported
+// through base64 encoding, but the format is not designed for them.
 //
 // # Txtar format
 //
@@ -25,65 +25,150 @@
 // file name can be surrounding by additional white space,
 // all of which is stripped.
 //
+// A file name that cannot be written this way, such as one with
+// leading or trailing spaces or a newline, is written as a
+// double-quoted Go string literal: "-- \" odd name \" --".
+//
 // If the txtar file is missing a trailing newline on the final line,
 // parsers should consider a final newline to be present anyway.
 //
+// A file marker line may end its file name with the flag "(quoted)",
+// as in "-- golden.txtar (quoted) --". In the data of such a file,
+// every line beginning with zero or more '>' characters followed by "-- "
+// has had one extra '>' prepended, which parsers remove.
+// This lets file data contain lines that would otherwise be file markers.
+//
+// A file marker line may instead end its file name with the flag "(base64)",
+// as in "-- logo.png (base64) --". The data of such a file is the
+// standard base64 encoding of the file content, split across lines.
+// Format uses it for data that is not valid UTF-8 or contains NUL bytes.
+//
+// A file marker line may also record attributes of the file after its name
+// and flag, as in "-- run.sh mode=0755 mtime=2024-01-02T15:04:05Z --".
+// The mode attribute holds the octal permission bits of the file and
+// the mtime attribute holds its modification time in RFC 3339 format.
+//
+// A file marker line of the form "-- NAME -> TARGET --" describes a
+// symbolic link named NAME pointing at TARGET. It has no file data.
+// A target that cannot be written as is is written as a Go string.
+//
 // There are no possible syntax errors in a txtar archive.
 package txtar
 
 import (
+	"bufio"
 	"bytes"
-	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"slices"
-	"strings"
+	"time"
 )
 
 // An Archive is a collection of files.
+//
+// Get, Has, Index, Set and Delete use an index of file names that is built
+// on first use. Files may still be changed directly; the index notices
+// when the slice is replaced, resliced or appended to, and when files
+// are moved around in it. Renaming a file in place by assigning to
+// Files[i].Name must be followed by a call to Reindex.
 type Archive struct {
 	Comment []byte
 	Files   []File
 }
 
 // A File is a single file in an archive.
+//
+// A symbolic link is a File whose Mode has fs.ModeSymlink set
+// and whose Data holds the link target.
 type File struct {
-	Name string // name of file ("foo/bar.txt")
-	Data []byte // text content of file
+	Name    string      // name of file ("foo/bar.txt")
+	Data    []byte      // text content of file
+	Mode    fs.FileMode // permission bits, or 0 if not recorded
+	ModTime time.Time   // modification time, or the zero time if not recorded; written and parsed in UTC
 }
 
 // Format returns the serialized form of an Archive.
 // It is assumed that the Archive data structure is well-formed:
-// a.Comment and all a.File[i].Data contain no file marker lines,
-// and all a.File[i].Name is non-empty.
+// a.Comment contains no file marker lines.
+// Files whose data contains file marker lines are written quoted,
+// files whose data is not valid UTF-8 or contains NUL bytes are written
+// base64 encoded, and file names that cannot be written as is are
+// written as Go strings.
 func Format(a *Archive) []byte {
-	size := len(a.Comment)
-	if size > 0 && a.Comment[size-1] != '\n' {
+	var buf bytes.Buffer
+	buf.Grow(int(FormatSize(a)))
+	a.writeTo(&buf)
+	return buf.Bytes()
+}
+
+// FormatSize returns the length of Format(a) without formatting a.
+func FormatSize(a *Archive) int64 {
+	size := int64(len(a.Comment))
+	if len(a.Comment) > 0 && a.Comment[len(a.Comment)-1] != '\n' {
 		size++
 	}
 	for _, f := range a.Files {
-		size += 3 + len(f.Name) + 4 // "-- " + f.Name + " --\n"
-		size += len(f.Data)
-		if len(f.Data) > 0 && f.Data[len(f.Data)-1] != '\n' {
+		h := headerFor(f)
+		size += int64(len(h.line()))
+		if h.link != "" {
+			continue
+		}
+		size += int64(h.enc.encodedLen(f.Data))
+		if h.enc != base64Encoded && len(f.Data) > 0 && f.Data[len(f.Data)-1] != '\n' {
 			size++
 		}
 	}
+	return size
+}
 
-	var buf bytes.Buffer
-	buf.Grow(size)
+// WriteTo writes the serialized form of a, as returned by Format, to w.
+// Only the data of one quoted or base64 encoded file at a time is held
+// in memory in encoded form. WriteTo implements io.WriterTo.
+func (a *Archive) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	err := a.writeTo(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	return cw.n, err
+}
 
-	buf.Write(a.Comment)
-	if len(a.Comment) > 0 && a.Comment[len(a.Comment)-1] != '\n' {
-		buf.WriteByte('\n')
+// writeTo writes the serialized form of a to w,
+// which should buffer small writes.
+func (a *Archive) writeTo(w io.Writer) error {
+	if _, err := w.Write(FixNL(a.Comment)); err != nil {
+		return err
 	}
 	for _, f := range a.Files {
-		fmt.Fprintf(&buf, "-- %s --\n", f.Name)
-		buf.Write(f.Data)
-		if len(f.Data) > 0 && f.Data[len(f.Data)-1] != '\n' {
-			buf.WriteByte('\n')
+		h := headerFor(f)
+		if _, err := io.WriteString(w, h.line()); err != nil {
+			return err
+		}
+		data := h.encode(f.Data)
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			if _, err := w.Write([]byte{'\n'}); err != nil {
+				return err
+			}
 		}
 	}
-	return buf.Bytes()
+	return nil
+}
+
+// countingWriter is an io.Writer that counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // ParseFile parses the named file as an archive.
@@ -94,55 +179,138 @@ func ParseFile(file string) (*Archive, error) {
 	}
 	defer f.Close()
 
-	r := NewReader(f)
 	a := new(Archive)
+	if _, err := a.ReadFrom(f); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// ReadFrom replaces the contents of a with the archive read from r
+// until EOF, returning the number of bytes read. The result is the same
+// as that of Parse. ReadFrom implements io.ReaderFrom.
+func (a *Archive) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	tr := NewReader(cr)
+	*a = Archive{}
 
 	// Read comment.
-	a.Comment, err = r.ReadComment()
+	comment, err := tr.ReadComment()
 	if err != nil {
-		return nil, err
+		return cr.n, err
 	}
-	a.Comment = FixNL(a.Comment)
+	a.Comment = FixNL(comment)
 
 	// Read files.
 	for {
-		header, err := r.Next()
+		header, err := tr.Next()
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
-			return nil, err
+			return cr.n, err
 		}
-		data, err := io.ReadAll(r)
+		data, err := io.ReadAll(tr)
 		if err != nil {
-			return nil, err
+			return cr.n, err
+		}
+		if tr.cur.text() {
+			data = FixNL(data)
 		}
-		header.Data = FixNL(data)
+		header.Data = data
 		a.Files = append(a.Files, header)
 	}
+	return cr.n, nil
+}
 
-	return a, nil
+// countingReader is an io.Reader that counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
 }
 
 // Parse parses the serialized form of an Archive.
-// The returned Archive holds slices of data.
+// The returned Archive holds slices of data,
+// except for the data of quoted files, which is copied.
 func Parse(data []byte) *Archive {
-	a := new(Archive)
-	var name string
-	a.Comment, name, data = findFileMarker(data)
-	for name != "" {
-		f := File{name, nil}
-		f.Data, name, data = findFileMarker(data)
-		a.Files = append(a.Files, f)
-	}
+	a, _ := parse(data, false)
 	return a
 }
 
 // Set replaces or adds a file with the given name and data to the archive.
-// If multiple files with the same name exist, all are removed before adding the new one.
+// A replaced file keeps its position in Files; a new file is added at the end.
+// If multiple files with the same name exist, the first is replaced and the
+// others are removed.
+// Any name is accepted: Format quotes names that cannot be written as is.
 func (a *Archive) Set(name string, data []byte) {
-	a.Delete(name)
-	a.Files = append(a.Files, File{Name: name, Data: data})
+	a.SetFile(File{Name: name, Data: data})
+}
+
+// SetFile replaces or adds the file f, including its attributes, in the archive.
+// It places f the same way as Set.
+func (a *Archive) SetFile(f File) {
+	x, i := a.lookup(f.Name)
+	if i < 0 {
+		a.appendFile(x, f)
+		return
+	}
+	a.Files[i] = f
+	if x.dups {
+		rest := slices.DeleteFunc(a.Files[i+1:], func(g File) bool {
+			return g.Name == f.Name
+		})
+		a.Files = a.Files[:i+1+len(rest)]
+	}
+}
+
+// Insert inserts f into the archive at index i of Files,
+// shifting the files at i and later up by one.
+// Any files with the same name as f are removed first;
+// i is an index into Files as it was before they were removed.
+// Insert panics if i is out of range.
+func (a *Archive) Insert(i int, f File) {
+	if a.Has(f.Name) {
+		for _, g := range a.Files[:i] {
+			if g.Name == f.Name {
+				i--
+			}
+		}
+		a.Files = slices.DeleteFunc(a.Files, func(g File) bool {
+			return g.Name == f.Name
+		})
+	}
+	a.Files = slices.Insert(a.Files, i, f)
+}
+
+// MoveBefore moves the file with the given name so that it comes directly
+// before the file named other. If other is empty, the file is moved to the end.
+// If there is no file with either name, MoveBefore returns an *fs.PathError
+// wrapping fs.ErrNotExist and leaves the archive unchanged.
+func (a *Archive) MoveBefore(name, other string) error {
+	i := a.Index(name)
+	if i < 0 {
+		return &fs.PathError{Op: "move", Path: name, Err: fs.ErrNotExist}
+	}
+	if other != "" && !a.Has(other) {
+		return &fs.PathError{Op: "move", Path: other, Err: fs.ErrNotExist}
+	}
+	if name == other {
+		return nil
+	}
+	f := a.Files[i]
+	a.Files = slices.Delete(a.Files, i, i+1)
+	j := len(a.Files)
+	if other != "" {
+		j = a.Index(other)
+	}
+	a.Files = slices.Insert(a.Files, j, f)
+	return nil
 }
 
 // SetComment replaces the archive comment with the given text.
@@ -151,7 +319,18 @@ func (a *Archive) SetComment(text string) {
 }
 
 // Delete removes all files with the given name from the archive.
+// The files after a removed one move down, so deleting the last file
+// takes constant time and deleting others takes time proportional
+// to the number of files that follow.
 func (a *Archive) Delete(name string) {
+	x, i := a.lookup(name)
+	if i < 0 {
+		return
+	}
+	if !x.dups {
+		a.deleteFile(x, i)
+		return
+	}
 	a.Files = slices.DeleteFunc(a.Files, func(f File) bool {
 		return f.Name == name
 	})
@@ -164,29 +343,31 @@ var (
 )
 
 // findFileMarker finds the next file marker in data,
-// extracts the file name, and returns the data before the marker,
-// the file name, and the data after the marker.
-// If there is no next marker, findFileMarker returns before = FixNL(data), name = "", after = nil.
-func findFileMarker(data []byte) (before []byte, name string, after []byte) {
+// parses the marker line, and returns the data before the marker,
+// the parsed marker, and the data after the marker.
+// If there is no next marker, findFileMarker returns before = FixNL(data), h = nil, after = nil.
+// The capacity of before ends with it, so that appending to it
+// cannot overwrite the marker.
+func findFileMarker(data []byte) (before []byte, h *header, after []byte) {
 	var i int
 	for {
-		if name, after = isMarker(data[i:]); name != "" {
-			return data[:i], name, after
+		if h, after = isMarker(data[i:]); h != nil {
+			return data[:i:i], h, after
 		}
 		j := bytes.Index(data[i:], newlineMarker)
 		if j < 0 {
-			return FixNL(data), "", nil
+			return FixNL(data), nil, nil
 		}
 		i += j + 1 // positioned at start of new possible marker
 	}
 }
 
 // isMarker checks whether data begins with a file marker line.
-// If so, it returns the name from the line and the data after the line.
-// Otherwise it returns name == "" with an unspecified after.
-func isMarker(data []byte) (name string, after []byte) {
+// If so, it returns the parsed marker line and the data after the line.
+// Otherwise it returns h == nil with an unspecified after.
+func isMarker(data []byte) (h *header, after []byte) {
 	if !bytes.HasPrefix(data, marker) {
-		return "", nil
+		return nil, nil
 	}
 	if i := bytes.IndexByte(data, '\n'); i >= 0 {
 		data, after = data[:i], data[i+1:]
@@ -195,9 +376,12 @@ func isMarker(data []byte) (name string, after []byte) {
 		}
 	}
 	if !(bytes.HasSuffix(data, markerEnd) && len(data) >= len(marker)+len(markerEnd)) {
-		return "", nil
+		return nil, nil
+	}
+	if h = parseHeader(string(data[len(marker) : len(data)-len(markerEnd)])); h == nil {
+		return nil, nil
 	}
-	return strings.TrimSpace(string(data[len(marker) : len(data)-len(markerEnd)])), after
+	return h, after
 }
 
 // If data is empty or ends in \n, FixNL returns data.
@@ -2,6 +2,8 @@ package txtar
 
 import (
 	"bytes"
+	"errors"
+	"io/fs"
 	"strings"
 	"testing"
 )
@@ -71,3 +73,131 @@ data1
 		t.Error("ReadComment should fail after Next")
 	}
 }
+
+func TestSetKeepsOrder(t *testing.T) {
+	a := Parse([]byte("-- a --\n-- b --\n-- c --\n-- b --\n"))
+	a.Set("b", []byte("new\n"))
+	if got := strings.Join(names(a), " "); got != "a b c" {
+		t.Errorf("after Set(b): files %s, want a b c", got)
+	}
+	if f, _ := a.Get("b"); string(f.Data) != "new\n" {
+		t.Errorf("Get(b) = %q, want new", f.Data)
+	}
+	a.Set("d", nil)
+	if got := strings.Join(names(a), " "); got != "a b c d" {
+		t.Errorf("after Set(d): files %s, want a b c d", got)
+	}
+}
+
+func TestInsert(t *testing.T) {
+	tests := []struct {
+		i    int
+		name string
+		want string
+	}{
+		{0, "x", "x a b c"},
+		{2, "x", "a b x c"},
+		{3, "x", "a b c x"},
+		{3, "a", "b c a"},
+		{0, "c", "c a b"},
+		{2, "b", "a b c"},
+	}
+	for _, tt := range tests {
+		a := Parse([]byte("-- a --\n-- b --\n-- c --\n"))
+		a.Insert(tt.i, File{Name: tt.name})
+		if got := strings.Join(names(a), " "); got != tt.want {
+			t.Errorf("Insert(%d, %s): files %s, want %s", tt.i, tt.name, got, tt.want)
+		}
+		if a.Index(tt.name) < 0 {
+			t.Errorf("Insert(%d, %s): Index = -1", tt.i, tt.name)
+		}
+	}
+}
+
+func TestMoveBefore(t *testing.T) {
+	tests := []struct {
+		name, other string
+		want        string
+		err         bool
+	}{
+		{"c", "a", "c a b", false},
+		{"a", "c", "b a c", false},
+		{"a", "", "b c a", false},
+		{"b", "b", "a b c", false},
+		{"x", "a", "a b c", true},
+		{"a", "x", "a b c", true},
+	}
+	for _, tt := range tests {
+		a := Parse([]byte("-- a --\n-- b --\n-- c --\n"))
+		err := a.MoveBefore(tt.name, tt.other)
+		if (err != nil) != tt.err {
+			t.Errorf("MoveBefore(%q, %q) error = %v", tt.name, tt.other, err)
+		}
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("MoveBefore(%q, %q) error = %v, want fs.ErrNotExist", tt.name, tt.other, err)
+		}
+		if got := strings.Join(names(a), " "); got != tt.want {
+			t.Errorf("MoveBefore(%q, %q): files %s, want %s", tt.name, tt.other, got, tt.want)
+		}
+	}
+}
+
+func TestWriteTo(t *testing.T) {
+	archives := []*Archive{
+		{},
+		{Comment: []byte("no newline")},
+		{
+			Comment: []byte("comment\n"),
+			Files: []File{
+				{Name: "plain", Data: []byte("text\n")},
+				{Name: "short", Data: []byte("no newline")},
+				{Name: "empty"},
+				{Name: "quoted", Data: []byte("-- inner --\n>-- x --")},
+				{Name: "binary", Data: bytes.Repeat([]byte{0, 1, 2}, 100)},
+				{Name: "link", Data: []byte("plain"), Mode: fs.ModeSymlink},
+				{Name: " odd name ", Data: []byte("x\n"), Mode: 0o755},
+			},
+		},
+	}
+	for i, a := range archives {
+		want := Format(a)
+		if got := FormatSize(a); got != int64(len(want)) {
+			t.Errorf("archive %d: FormatSize = %d, want %d", i, got, len(want))
+		}
+		var buf bytes.Buffer
+		n, err := a.WriteTo(&buf)
+		if err != nil || n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("archive %d: WriteTo = %d, %v, wrote:\n%s\nwant:\n%s", i, n, err, buf.Bytes(), want)
+		}
+
+		got := &Archive{Comment: []byte("old"), Files: []File{{Name: "old"}}}
+		n, err = got.ReadFrom(bytes.NewReader(want))
+		if err != nil || n != int64(len(want)) {
+			t.Errorf("archive %d: ReadFrom = %d, %v, want %d, nil", i, n, err, len(want))
+		}
+		if !bytes.Equal(Format(got), want) {
+			t.Errorf("archive %d: ReadFrom read:\n%s\nwant:\n%s", i, Format(got), want)
+		}
+	}
+}
+
+func TestWriteToError(t *testing.T) {
+	a := &Archive{Files: []File{{Name: "big", Data: bytes.Repeat([]byte("x\n"), 10000)}}}
+	n, err := a.WriteTo(&limitedWriter{n: 5000})
+	if err == nil || n != 5000 {
+		t.Errorf("WriteTo = %d, %v, want 5000 and an error", n, err)
+	}
+}
+
+// limitedWriter accepts n bytes and then fails.
+type limitedWriter struct{ n int }
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errors.New("short write")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
@@ -36,11 +36,11 @@ some content
 			parsed: &Archive{
 				Comment: []byte("comment1\ncomment2\n"),
 				Files: []File{
-					{"file1", []byte("File 1 text.\n-- foo ---\nMore file 1 text.\n")},
-					{"file 2", []byte("File 2 text.\n")},
-					{"empty", []byte{}},
-					{"noNL", []byte("hello world\n")},
-					{"empty filename line", []byte("some content\n-- --\n")},
+					{Name: "file1", Data: []byte("File 1 text.\n-- foo ---\nMore file 1 text.\n")},
+					{Name: "file 2", Data: []byte("File 2 text.\n")},
+					{Name: "empty", Data: []byte{}},
+					{Name: "noNL", Data: []byte("hello world\n")},
+					{Name: "empty filename line", Data: []byte("some content\n-- --\n")},
 				},
 			},
 		},
@@ -49,7 +49,7 @@ some content
 			text: "comment\r\n-- file --\r\ndata\r\n",
 			parsed: &Archive{
 				Comment: []byte("comment\r\n"),
-				Files:   []File{{"file", []byte("data\r\n")}},
+				Files:   []File{{Name: "file", Data: []byte("data\r\n")}},
 			},
 		},
 	}
@@ -79,10 +79,10 @@ func TestFormat(t *testing.T) {
 			input: &Archive{
 				Comment: []byte("comment1\ncomment2\n"),
 				Files: []File{
-					{"file1", []byte("File 1 text.\n-- foo ---\nMore file 1 text.\n")},
-					{"file 2", []byte("File 2 text.\n")},
-					{"empty", []byte{}},
-					{"noNL", []byte("hello world")},
+					{Name: "file1", Data: []byte("File 1 text.\n-- foo ---\nMore file 1 text.\n")},
+					{Name: "file 2", Data: []byte("File 2 text.\n")},
+					{Name: "empty", Data: []byte{}},
+					{Name: "noNL", Data: []byte("hello world")},
 				},
 			},
 			wanted: `comment1
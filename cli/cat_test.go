@@ -145,3 +145,30 @@ func TestCat(t *testing.T) {
 		})
 	}
 }
+
+func TestCatNoFinalNewline(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "test.txtar")
+	if err := os.WriteFile(archivePath, []byte("-- a --\nfirst\n-- b --\nno newline"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, files := range [][]string{nil, {"b"}} {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		Cat(archivePath, true, files...)
+
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		want := "first\nno newline"
+		if files != nil {
+			want = "no newline"
+		}
+		if got := buf.String(); got != want {
+			t.Errorf("Cat(%q) = %q, want %q", files, got, want)
+		}
+	}
+}
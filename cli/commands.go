@@ -1,8 +1,11 @@
 package cli
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,11 +19,16 @@ import (
 //	recursive:	-r --recursive	(default: false)	Recursive
 //	trim:		-t --trim		(default: false)	Trim directory prefix
 //	follow:		-f --follow		(default: false)	Follow symlinks
+//	preserve:	-p --preserve	(default: false)	Record file mode and modification time
 //	name:		--name			(default: "")		Name filter (glob pattern)
 //	depth:		--depth			(default: -1)		Max depth
 //	files:		...				Files/dirs to add
-func Create(recursive bool, trim bool, follow bool, name string, depth int, files ...string) {
-	a := new(txtar.Archive)
+func Create(recursive bool, trim bool, follow bool, preserve bool, name string, depth int, files ...string) {
+	// The walk only collects the files to add, so that when several paths
+	// are stored under the same name the last one found wins. Their data
+	// is read as they are written.
+	var entries []createEntry
+	last := make(map[string]int)
 	for _, file := range files {
 		err := filepath.WalkDir(file, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
@@ -48,11 +56,6 @@ func Create(recursive bool, trim bool, follow bool, name string, depth int, file
 				return nil
 			}
 
-			// Skip symlinks to avoid including files outside the intended scope
-			if !follow && info.Mode()&os.ModeSymlink != 0 {
-				return nil
-			}
-
 			if depth >= 0 && depthCount > depth {
 				return nil
 			}
@@ -65,11 +68,6 @@ func Create(recursive bool, trim bool, follow bool, name string, depth int, file
 				}
 			}
 
-			data, err := os.ReadFile(path)
-			if err != nil {
-				return err
-			}
-
 			storeName := path
 			if trim {
 				if rel == "." {
@@ -79,14 +77,84 @@ func Create(recursive bool, trim bool, follow bool, name string, depth int, file
 				}
 			}
 
-			a.Set(storeName, data)
+			// Store symlinks as link entries to avoid including files outside the intended scope
+			link := !follow && d.Type()&fs.ModeSymlink != 0
+			entries = append(entries, createEntry{path: path, name: storeName, link: link})
+			last[storeName] = len(entries) - 1
 			return nil
 		})
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "Error walking path %s: %v\n", file, err)
 		}
 	}
-	fmt.Print(string(txtar.Format(a)))
+
+	out := bufio.NewWriter(os.Stdout)
+	tw := txtar.NewWriter(out)
+	var err error
+	for i, e := range entries {
+		if last[e.name] != i {
+			continue
+		}
+		f, rerr := e.file(preserve)
+		if rerr != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.path, rerr)
+			continue
+		}
+		if err = tw.WriteFile(f); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = out.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// A createEntry is a file found by Create, to be stored under name.
+type createEntry struct {
+	path string
+	name string
+	link bool // store the symlink itself rather than its target
+}
+
+// file reads the file e describes.
+func (e createEntry) file(preserve bool) (txtar.File, error) {
+	if e.link {
+		target, err := os.Readlink(e.path)
+		if err != nil {
+			return txtar.File{}, err
+		}
+		f := txtar.File{Name: e.name, Data: []byte(filepath.ToSlash(target)), Mode: fs.ModeSymlink}
+		if preserve {
+			info, err := os.Lstat(e.path)
+			if err != nil {
+				return txtar.File{}, err
+			}
+			f.ModTime = info.ModTime()
+		}
+		return f, nil
+	}
+
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return txtar.File{}, err
+	}
+	f := txtar.File{Name: e.name, Data: data}
+	if preserve {
+		info, err := os.Stat(e.path)
+		if err != nil {
+			return txtar.File{}, err
+		}
+		f.Mode = info.Mode().Perm()
+		f.ModTime = info.ModTime()
+	}
+	return f, nil
 }
 
 // List is a subcommand `txtar list` -- List files in archive with index, offset, size, name
@@ -102,69 +170,85 @@ func List(archive string) {
 	}
 	defer f.Close()
 
-	r := txtar.NewReader(f)
-
-	// Calculate offsets
-	// Start with comment
-	comment, err := r.ReadComment()
+	x, err := archiveIndex(archive, f)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading archive comment: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error reading archive entry: %v\n", err)
 		os.Exit(1)
 	}
-	offset := int64(len(txtar.FixNL(comment)))
-
-	// Reuse buffer for reading content
-	buf := make([]byte, 32*1024)
-
-	i := 0
-	for {
-		header, err := r.Next()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading archive entry: %v\n", err)
-			os.Exit(1)
-		}
-
-		realSize, endsInNL, err := consumeAndCount(r, buf)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading archive content: %v\n", err)
-			os.Exit(1)
-		}
-
-		size := realSize
-		if realSize > 0 && !endsInNL {
-			size++
-		}
+	for i, e := range x.Entries {
+		fmt.Printf("%d %d %d %s\n", i, e.Offset, e.Size, e.Name)
+	}
+}
 
-		fmt.Printf("%d %d %d %s\n", i, offset, size, header.Name)
+// archiveIndex returns the index of the archive f, which is the file named
+// archive. It reads the sidecar index file if it is up to date and builds
+// the index from f otherwise.
+func archiveIndex(archive string, f *os.File) (*txtar.Index, error) {
+	if x, err := txtar.ReadIndexFile(archive); err == nil {
+		return x, nil
+	}
+	return txtar.BuildIndex(f)
+}
 
-		marker := fmt.Sprintf("-- %s --\n", header.Name)
-		offset += int64(len(marker))
-		offset += size
+// writeArchive writes a to the named file, streaming it
+// instead of formatting it in memory first. It writes to a temporary
+// file in the same directory and renames it over the named file,
+// so a failure leaves the file as it was.
+func writeArchive(name string, a *txtar.Archive) error {
+	if target, err := filepath.EvalSymlinks(name); err == nil {
+		name = target // replace the file a symlink points to, not the link
+	}
+	perm := fs.FileMode(0644)
+	if info, err := os.Stat(name); err == nil {
+		perm = info.Mode().Perm()
+	}
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = a.WriteTo(f)
+	if err == nil {
+		err = f.Chmod(perm)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
 
-		i++
+// Index is a subcommand `txtar index` -- Write a sidecar index file for archive
+//
+// Flags:
+//
+//	archive:	@1	Archive file
+//
+// list and cat use the index instead of scanning the archive
+// until the archive is changed.
+func Index(archive string) {
+	if err := writeIndex(archive); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 }
 
-func consumeAndCount(r io.Reader, buf []byte) (int64, bool, error) {
-	var size int64
-	var lastByte byte
-	for {
-		n, err := r.Read(buf)
-		if n > 0 {
-			size += int64(n)
-			lastByte = buf[n-1]
-		}
-		if err == io.EOF {
-			// If size > 0, check if last byte was newline
-			return size, size > 0 && lastByte == '\n', nil
-		}
-		if err != nil {
-			return size, false, err
-		}
+// writeIndex indexes the archive file and writes its sidecar index file.
+func writeIndex(archive string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
 	}
+	defer f.Close()
+	x, err := txtar.BuildIndex(f)
+	if err != nil {
+		return err
+	}
+	return txtar.WriteIndexFile(archive, x)
 }
 
 // Add is a subcommand `txtar add` -- Add files to archive
@@ -173,9 +257,13 @@ func consumeAndCount(r io.Reader, buf []byte) (int64, bool, error) {
 //
 //	recursive:	-r --recursive	(default: false)	Recursive
 //	follow:		-f --follow		(default: false)	Follow symlinks
+//	before:		--before		(default: "")		Insert new files before this file
 //	archive:	@1	Archive file
 //	files:		...	Files to add
-func Add(recursive bool, follow bool, archive string, files ...string) {
+//
+// Files already in the archive are replaced where they are. New files are
+// added at the end, or before the file named by before.
+func Add(recursive bool, follow bool, before string, archive string, files ...string) {
 	a, err := txtar.ParseFile(archive)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -185,6 +273,18 @@ func Add(recursive bool, follow bool, archive string, files ...string) {
 			os.Exit(1)
 		}
 	}
+	if before != "" && !a.Has(before) {
+		fmt.Fprintf(os.Stderr, "Error: file %s not found in archive\n", before)
+		os.Exit(1)
+	}
+
+	set := func(name string, data []byte) {
+		existed := a.Has(name)
+		a.Set(name, data)
+		if !existed && before != "" {
+			a.MoveBefore(name, before)
+		}
+	}
 
 	for _, file := range files {
 		if recursive {
@@ -203,7 +303,7 @@ func Add(recursive bool, follow bool, archive string, files ...string) {
 				if err != nil {
 					return err
 				}
-				a.Set(path, data)
+				set(path, data)
 				return nil
 			})
 		} else {
@@ -221,11 +321,11 @@ func Add(recursive bool, follow bool, archive string, files ...string) {
 				fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", file, err)
 				continue
 			}
-			a.Set(file, data)
+			set(file, data)
 		}
 	}
 
-	if err := os.WriteFile(archive, txtar.Format(a), 0644); err != nil {
+	if err := writeArchive(archive, a); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
 		os.Exit(1)
 	}
@@ -240,7 +340,7 @@ func Add(recursive bool, follow bool, archive string, files ...string) {
 //	archive:	@1	Archive file
 //	files:		...	Files to append
 func Append(recursive bool, follow bool, archive string, files ...string) {
-	Add(recursive, follow, archive, files...)
+	Add(recursive, follow, "", archive, files...)
 }
 
 // Delete is a subcommand `txtar delete` -- Delete files from archive
@@ -278,7 +378,7 @@ func Delete(archive string, files ...string) {
 		a.Delete(name)
 	}
 
-	if err := os.WriteFile(archive, txtar.Format(a), 0644); err != nil {
+	if err := writeArchive(archive, a); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
 		os.Exit(1)
 	}
@@ -332,41 +432,34 @@ func Cat(archive string, txt bool, files ...string) {
 		return
 	}
 
+	f, err := os.Open(archive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	x, err := archiveIndex(archive, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+		os.Exit(1)
+	}
+
 	for _, file := range files {
 		found := false
-		err := func() error {
-			f, err := os.Open(archive)
-			if err != nil {
-				return err
+		for _, e := range x.Entries {
+			if matched, _ := filepath.Match(file, e.Name); !matched {
+				continue
 			}
-			defer f.Close()
-
-			r := txtar.NewReader(f)
-			for {
-				hdr, err := r.Next()
-				if err == io.EOF {
-					break
-				}
-				if err != nil {
-					return err
-				}
-
-				matched, _ := filepath.Match(file, hdr.Name)
-				if matched {
-					if _, err := io.Copy(os.Stdout, r); err != nil {
-						// Error writing to stdout, probably fatal
-						fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
-						os.Exit(1)
-					}
-					found = true
-				}
+			r, err := e.Open(f)
+			if err == nil {
+				_, err = io.Copy(os.Stdout, r)
 			}
-			return nil
-		}()
-
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
-			os.Exit(1)
+			if err != nil {
+				// Reading the archive or writing to stdout, probably fatal
+				fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
+				os.Exit(1)
+			}
+			found = true
 		}
 
 		if !found {
@@ -390,7 +483,7 @@ func Comment(comment string, file string, archive string) {
 	}
 
 	if comment == "" && file == "" {
-		fmt.Print(string(a.Comment))
+		os.Stdout.Write(a.Comment)
 		return
 	}
 
@@ -419,8 +512,230 @@ func Comment(comment string, file string, archive string) {
 	}
 
 	a.SetComment(text)
-	if err := os.WriteFile(archive, txtar.Format(a), 0644); err != nil {
+	if err := writeArchive(archive, a); err != nil {
 		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
 		os.Exit(1)
 	}
 }
+
+// Merge is a subcommand `txtar merge` -- Merge archives into one
+//
+// Flags:
+//
+//	onConflict:	--on-conflict	(default: "error-on-conflict")	Conflict policy: keep-first, keep-last, error-on-conflict or rename-with-suffix
+//	out:		@1	Output archive
+//	archives:	...	Archives to merge
+func Merge(onConflict string, out string, archives ...string) {
+	policy, err := txtar.ParseMergePolicy(onConflict)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	a := new(txtar.Archive)
+	for _, archive := range archives {
+		src, err := txtar.ParseFile(archive)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+			os.Exit(1)
+		}
+		conflicts, err := txtar.Merge(a, src, policy)
+		for _, c := range conflicts {
+			if c.Renamed != "" {
+				fmt.Fprintf(os.Stderr, "%s: conflict on %s, added as %s\n", archive, c.Name, c.Renamed)
+			} else {
+				fmt.Fprintf(os.Stderr, "%s: conflict on %s\n", archive, c.Name)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging %s: %v\n", archive, err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writeArchive(out, a); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Diff is a subcommand `txtar diff` -- Show differences between two archives
+//
+// Flags:
+//
+//	json:	--json	(default: false)	Write changes as JSON
+//	old:	@1	Old archive
+//	new:	@2	New archive
+//
+// Diff exits with status 0 if the archives are the same, 1 if they differ, and 2 on error.
+func Diff(json bool, old string, new string) {
+	differ, err := diffArchives(os.Stdout, json, old, new)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if differ {
+		os.Exit(1)
+	}
+}
+
+// diffArchives writes the differences between the archives old and new to w,
+// and reports whether there were any.
+func diffArchives(w io.Writer, asJSON bool, old string, new string) (bool, error) {
+	a, err := txtar.ParseFile(old)
+	if err != nil {
+		return false, err
+	}
+	b, err := txtar.ParseFile(new)
+	if err != nil {
+		return false, err
+	}
+	changes := txtar.Diff(a, b)
+
+	if asJSON {
+		if changes == nil {
+			changes = []txtar.Change{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return len(changes) > 0, enc.Encode(changes)
+	}
+
+	for _, c := range changes {
+		switch c.Kind {
+		case txtar.CommentChanged:
+			fmt.Fprintf(w, "%s\n", c.Kind)
+		case txtar.Renamed:
+			fmt.Fprintf(w, "%s %s -> %s\n", c.Kind, c.OldName, c.Name)
+		default:
+			fmt.Fprintf(w, "%s %s\n", c.Kind, c.Name)
+		}
+		if _, err := io.WriteString(w, c.Diff); err != nil {
+			return false, err
+		}
+	}
+	return len(changes) > 0, nil
+}
+
+// Patch is a subcommand `txtar patch` -- Apply a unified diff from stdin to archive
+//
+// Flags:
+//
+//	archive:	@1	Archive file
+//
+// The archive is only written if every hunk applies.
+func Patch(archive string) {
+	if err := patchArchive(archive, os.Stdin); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// patchArchive applies the patch read from r to the archive file.
+func patchArchive(archive string, r io.Reader) error {
+	a, err := txtar.ParseFile(archive)
+	if err != nil {
+		return err
+	}
+	if err := txtar.ApplyPatch(a, r); err != nil {
+		return err
+	}
+	return writeArchive(archive, a)
+}
+
+// MergeDriver is a subcommand `txtar merge-driver` -- Three-way merge archives, for use as a git merge driver
+//
+// Flags:
+//
+//	base:	@1	Common ancestor archive (%O)
+//	ours:	@2	Our archive, overwritten with the result (%A)
+//	theirs:	@3	Their archive (%B)
+//
+// MergeDriver exits with status 1 if conflicts remain. To use it, add
+// `*.txtar merge=txtar` to .gitattributes and set merge.txtar.driver to
+// `txtar merge-driver %O %A %B` in the git configuration.
+func MergeDriver(base string, ours string, theirs string) {
+	conflicts, err := mergeDriver(base, ours, theirs)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, c := range conflicts {
+		if c.Name == "" {
+			fmt.Fprintf(os.Stderr, "Conflict in archive comment\n")
+		} else {
+			fmt.Fprintf(os.Stderr, "Conflict in %s\n", c.Name)
+		}
+	}
+	if len(conflicts) > 0 {
+		os.Exit(1)
+	}
+}
+
+// mergeDriver merges the archives base, ours and theirs, writing the result to ours.
+func mergeDriver(base string, ours string, theirs string) ([]txtar.Conflict, error) {
+	var archives [3]*txtar.Archive
+	for i, name := range []string{base, ours, theirs} {
+		a, err := txtar.ParseFile(name)
+		if err != nil {
+			return nil, err
+		}
+		archives[i] = a
+	}
+	merged, conflicts := txtar.Merge3(archives[0], archives[1], archives[2])
+	if err := writeArchive(ours, merged); err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+// Delta is a subcommand `txtar delta` -- Write an archive of the changes from old to new
+//
+// Flags:
+//
+//	old:	@1	Old archive
+//	new:	@2	New archive
+func Delta(old string, new string) {
+	a, err := txtar.ParseFile(old)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+		os.Exit(1)
+	}
+	b, err := txtar.ParseFile(new)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := txtar.Delta(a, b).WriteTo(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// ApplyDelta is a subcommand `txtar apply-delta` -- Apply a delta archive and write the result
+//
+// Flags:
+//
+//	base:	@1	Archive the delta was made against
+//	delta:	@2	Delta archive
+func ApplyDelta(base string, delta string) {
+	a, err := txtar.ParseFile(base)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+		os.Exit(1)
+	}
+	d, err := txtar.ParseFile(delta)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing archive: %v\n", err)
+		os.Exit(1)
+	}
+	result, err := txtar.ApplyDelta(a, d)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error applying delta: %v\n", err)
+		os.Exit(1)
+	}
+	if _, err := result.WriteTo(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing to stdout: %v\n", err)
+		os.Exit(1)
+	}
+}
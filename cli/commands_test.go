@@ -2,11 +2,15 @@ package cli
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 	"txtar"
 )
 
@@ -226,7 +230,7 @@ func TestCreate(t *testing.T) {
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
-			Create(tt.recursive, tt.trim, false, tt.glob, tt.depth, tt.files...)
+			Create(tt.recursive, tt.trim, false, false, tt.glob, tt.depth, tt.files...)
 
 			w.Close()
 			os.Stdout = oldStdout
@@ -249,7 +253,38 @@ func TestCreate(t *testing.T) {
 	}
 }
 
-func TestCat(t *testing.T) {
+func TestCreateDuplicateNames(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"first", "second"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, dir, "sub"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, dir, "sub", "x.txt"), []byte(dir+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "first", "only.txt"), []byte("only\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	Create(true, true, false, false, "", -1, filepath.Join(tmpDir, "first"), filepath.Join(tmpDir, "second"))
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	want := "-- only.txt --\nonly\n-- sub/x.txt --\nsecond\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Create output:\n%s\nwant (last duplicate wins):\n%s", got, want)
+	}
+}
+
+func TestCatPatterns(t *testing.T) {
 	// Setup temporary directory
 	tmpDir := t.TempDir()
 	archivePath := filepath.Join(tmpDir, "test.txtar")
@@ -447,3 +482,366 @@ func TestComment(t *testing.T) {
 		}
 	})
 }
+
+func TestBinaryFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	binary := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	binFile := filepath.Join(tmpDir, "logo.png")
+	if err := os.WriteFile(binFile, binary, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Create", func(t *testing.T) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		Create(false, true, false, false, "", -1, binFile)
+
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		if !strings.Contains(buf.String(), "-- logo.png (base64) --\n") {
+			t.Errorf("expected base64 entry, got:\n%q", buf.String())
+		}
+		a := txtar.Parse(buf.Bytes())
+		if len(a.Files) != 1 || !bytes.Equal(a.Files[0].Data, binary) {
+			t.Errorf("binary data did not round-trip: %q", a.Files)
+		}
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		archivePath := filepath.Join(tmpDir, "archive.txtar")
+		Add(false, false, "", archivePath, binFile)
+
+		data, err := os.ReadFile(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(data, []byte{0}) {
+			t.Errorf("archive contains raw binary data:\n%q", data)
+		}
+		a, err := txtar.ParseFile(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(a.Files) != 1 || !bytes.Equal(a.Files[0].Data, binary) {
+			t.Errorf("binary data did not round-trip: %q", a.Files)
+		}
+	})
+}
+
+func TestCreatePreserve(t *testing.T) {
+	tmpDir := t.TempDir()
+	script := filepath.Join(tmpDir, "run.sh")
+	if err := os.WriteFile(script, []byte("echo hi\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(script, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, preserve := range []bool{false, true} {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+
+		Create(false, true, false, preserve, "", -1, script)
+
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		a := txtar.Parse(buf.Bytes())
+		if len(a.Files) != 1 {
+			t.Fatalf("preserve=%v: got %d files, want 1", preserve, len(a.Files))
+		}
+		f := a.Files[0]
+		if !preserve {
+			if f.Mode != 0 || !f.ModTime.IsZero() {
+				t.Errorf("preserve=false: recorded mode %v, mtime %v", f.Mode, f.ModTime)
+			}
+			continue
+		}
+		if f.Mode != 0755 {
+			t.Errorf("preserve=true: mode = %v, want 0755", f.Mode)
+		}
+		if !f.ModTime.Equal(mtime) {
+			t.Errorf("preserve=true: mtime = %v, want %v", f.ModTime, mtime)
+		}
+	}
+}
+
+func TestAddOrder(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{"a", "b", "new1", "new2"} {
+		if err := os.WriteFile(name, []byte(name+" updated\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		before string
+		files  []string
+		want   string
+	}{
+		{"replace keeps position", "", []string{"a"}, "a b c"},
+		{"new files at end", "", []string{"new1", "b"}, "a b c new1"},
+		{"before", "b", []string{"new1", "a", "new2"}, "a new1 new2 b c"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			const archive = "archive.txtar"
+			if err := os.WriteFile(archive, []byte("-- a --\na\n-- b --\nb\n-- c --\nc\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			Add(false, false, tt.before, archive, tt.files...)
+
+			a, err := txtar.ParseFile(archive)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, f := range a.Files {
+				names = append(names, f.Name)
+			}
+			if got := strings.Join(names, " "); got != tt.want {
+				t.Errorf("files %s, want %s", got, tt.want)
+			}
+			if f, _ := a.Get("a"); slices.Contains(tt.files, "a") != (string(f.Data) == "a updated\n") {
+				t.Errorf("a = %q", f.Data)
+			}
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.txtar")
+	second := filepath.Join(dir, "second.txtar")
+	if err := os.WriteFile(first, []byte("from a\n-- a.txt --\na\n-- b.txt --\nb\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(second, []byte("from b\n-- b.txt --\nB\n-- c.txt --\nc\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		policy string
+		want   string
+	}{
+		{"keep-first", "from a\nfrom b\n-- a.txt --\na\n-- b.txt --\nb\n-- c.txt --\nc\n"},
+		{"keep-last", "from a\nfrom b\n-- a.txt --\na\n-- b.txt --\nB\n-- c.txt --\nc\n"},
+		{"rename-with-suffix", "from a\nfrom b\n-- a.txt --\na\n-- b.txt --\nb\n-- b-1.txt --\nB\n-- c.txt --\nc\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			out := filepath.Join(dir, tt.policy+".txtar")
+
+			oldStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			Merge(tt.policy, out, first, second)
+			w.Close()
+			os.Stderr = oldStderr
+
+			var stderr bytes.Buffer
+			io.Copy(&stderr, r)
+			if !strings.Contains(stderr.String(), "conflict on b.txt") {
+				t.Errorf("conflict not reported, stderr:\n%s", stderr.String())
+			}
+
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("merged archive:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffArchives(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txtar")
+	new := filepath.Join(dir, "new.txtar")
+	if err := os.WriteFile(old, []byte("-- a --\n1\n-- b --\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(new, []byte("-- b --\n2\n-- a --\none\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	differ, err := diffArchives(&buf, false, old, new)
+	if err != nil || !differ {
+		t.Fatalf("diffArchives = %v, %v; want true, nil", differ, err)
+	}
+	want := "modified a\n--- a/a\n+++ b/a\n@@ -1 +1 @@\n-1\n+one\n"
+	if buf.String() != want {
+		t.Errorf("text output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	if _, err := diffArchives(&buf, true, old, new); err != nil {
+		t.Fatal(err)
+	}
+	var changes []txtar.Change
+	if err := json.Unmarshal(buf.Bytes(), &changes); err != nil {
+		t.Fatalf("invalid JSON output %q: %v", buf.String(), err)
+	}
+	if len(changes) != 1 || changes[0].Kind != txtar.Modified || changes[0].Name != "a" {
+		t.Errorf("JSON changes = %+v", changes)
+	}
+
+	buf.Reset()
+	differ, err = diffArchives(&buf, true, old, old)
+	if err != nil || differ {
+		t.Errorf("diffArchives(old, old) = %v, %v; want false, nil", differ, err)
+	}
+	if got := strings.TrimSpace(buf.String()); got != "[]" {
+		t.Errorf("JSON output for no changes = %q, want []", got)
+	}
+
+	if _, err := diffArchives(&buf, false, old, filepath.Join(dir, "missing")); err == nil {
+		t.Errorf("diffArchives with missing archive succeeded")
+	}
+}
+
+func TestPatchArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.txtar")
+	const original = "-- a.txt --\none\n-- b.txt --\ntwo\n"
+	if err := os.WriteFile(archive, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second file does not apply, so nothing is written.
+	bad := "--- a/a.txt\n+++ b/a.txt\n@@ -1 +1 @@\n-one\n+1\n--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-three\n+3\n"
+	if err := patchArchive(archive, strings.NewReader(bad)); !errors.Is(err, txtar.ErrHunkMismatch) {
+		t.Errorf("patchArchive error = %v, want ErrHunkMismatch", err)
+	}
+	if data, _ := os.ReadFile(archive); string(data) != original {
+		t.Errorf("archive written after failed patch:\n%s", data)
+	}
+
+	good := "--- a/b.txt\n+++ b/b.txt\n@@ -1 +1 @@\n-two\n+2\n"
+	if err := patchArchive(archive, strings.NewReader(good)); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(archive); string(data) != "-- a.txt --\none\n-- b.txt --\n2\n" {
+		t.Errorf("patched archive:\n%s", data)
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "archive.txtar")
+	if err := os.WriteFile(archive, []byte("-- old --\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	a := txtar.Parse([]byte("-- new --\n"))
+	if err := writeArchive(archive, a); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(archive); string(data) != "-- new --\n" {
+		t.Errorf("archive = %q", data)
+	}
+	if info, err := os.Stat(archive); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("archive mode = %v, %v; want 0600", info.Mode(), err)
+	}
+
+	// Renaming over a directory fails, leaving it and no temporary file.
+	sub := filepath.Join(dir, "sub")
+	if err := os.Mkdir(sub, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeArchive(sub, a); err == nil {
+		t.Errorf("writeArchive over a directory succeeded")
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		var names []string
+		for _, e := range entries {
+			names = append(names, e.Name())
+		}
+		t.Errorf("directory holds %v, want archive.txtar and sub", names)
+	}
+}
+
+func TestMergeDriver(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	base := write("base", "-- a --\n1\n-- b --\n2\n")
+	ours := write("ours", "-- a --\none\n-- b --\n2\n")
+	theirs := write("theirs", "-- a --\n1\n-- b --\ntwo\n-- c --\n3\n")
+
+	conflicts, err := mergeDriver(base, ours, theirs)
+	if err != nil || len(conflicts) != 0 {
+		t.Fatalf("mergeDriver = %v, %v; want no conflicts", conflicts, err)
+	}
+	if data, _ := os.ReadFile(ours); string(data) != "-- a --\none\n-- b --\ntwo\n-- c --\n3\n" {
+		t.Errorf("merged archive:\n%s", data)
+	}
+
+	ours = write("ours", "-- a --\nours\n-- b --\n2\n")
+	theirs = write("theirs", "-- a --\ntheirs\n-- b --\n2\n")
+	conflicts, err = mergeDriver(base, ours, theirs)
+	if err != nil || len(conflicts) != 1 || conflicts[0].Name != "a" {
+		t.Fatalf("mergeDriver = %v, %v; want a conflict in a", conflicts, err)
+	}
+	want := "-- a --\n<<<<<<< ours\nours\n=======\ntheirs\n>>>>>>> theirs\n-- b --\n2\n"
+	if data, _ := os.ReadFile(ours); string(data) != want {
+		t.Errorf("merged archive:\n%s\nwant:\n%s", data, want)
+	}
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	old := filepath.Join(dir, "old.txtar")
+	new := filepath.Join(dir, "new.txtar")
+	delta := filepath.Join(dir, "delta.txtar")
+	if err := os.WriteFile(old, []byte("-- a --\n1\n-- b --\n2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	const want = "-- a --\none\n-- c --\n3\n"
+	if err := os.WriteFile(new, []byte(want), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	capture := func(f func()) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		f()
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	d := capture(func() { Delta(old, new) })
+	if !strings.Contains(d, "delete \"b\"\n") || strings.Contains(d, "-- a --\n1\n") {
+		t.Errorf("delta archive:\n%s", d)
+	}
+	if err := os.WriteFile(delta, []byte(d), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got := capture(func() { ApplyDelta(old, delta) }); got != want {
+		t.Errorf("apply-delta output:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -41,7 +41,39 @@ func BenchmarkCreate(b *testing.B) {
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		// Run Create on the temp directory
-		// We use recursive=true, trim=false, glob="", depth=-1
-		Create(true, false, "", -1, tmpDir)
+		// We use recursive=true, trim=false, follow=false, preserve=false, glob="", depth=-1
+		Create(true, false, false, false, "", -1, tmpDir)
+	}
+}
+
+// BenchmarkCreateScaling creates archives of increasing size from a flat
+// directory. The time per file should stay roughly constant as the number
+// of files grows.
+func BenchmarkCreateScaling(b *testing.B) {
+	for _, n := range []int{1000, 5000, 20000} {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			dir := b.TempDir()
+			for i := 0; i < n; i++ {
+				file := filepath.Join(dir, fmt.Sprintf("file%d.txt", i))
+				if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+					b.Fatal(err)
+				}
+			}
+
+			oldStdout := os.Stdout
+			defer func() { os.Stdout = oldStdout }()
+			f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer f.Close()
+			os.Stdout = f
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				Create(false, true, false, false, "", -1, dir)
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/file")
+		})
 	}
 }
@@ -45,6 +45,22 @@ content2
 				"1 12 0 file2",
 			},
 		},
+		{
+			name:    "extra marker spaces",
+			content: "--  file1  --\nabc\n-- file2 --\nx\n",
+			expected: []string{
+				"0 0 4 file1",
+				"1 18 2 file2",
+			},
+		},
+		{
+			name:    "crlf markers",
+			content: "c\r\n-- file1 --\r\nabc\r\n-- file2 --\r\nx\r\n",
+			expected: []string{
+				"0 3 5 file1",
+				"1 21 3 file2",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -76,3 +92,40 @@ content2
 		})
 	}
 }
+
+func TestListIndexed(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "a.txtar")
+	list := func() string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		List(archivePath)
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		io.Copy(&buf, r)
+		return buf.String()
+	}
+
+	if err := os.WriteFile(archivePath, []byte("-- file1 --\nabc\n-- dir/file2 --\nx"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := list()
+	if err := writeIndex(archivePath); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(archivePath + ".idx"); err != nil {
+		t.Fatal(err)
+	}
+	if got := list(); got != want {
+		t.Errorf("List with index:\n%s\nwant:\n%s", got, want)
+	}
+
+	// A changed archive makes the index stale.
+	if err := os.WriteFile(archivePath, []byte("-- other --\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := list(), "0 0 0 other\n"; got != want {
+		t.Errorf("List after change = %q, want %q", got, want)
+	}
+}
@@ -38,8 +38,8 @@ func TestSymlinkVulnerability(t *testing.T) {
 	os.Stdout = w
 
 	// Run Create on the target directory
-	// recursive=true, trim=false, follow=false, name="", depth=-1, files=[targetDir]
-	Create(true, false, false, "", -1, targetDir)
+	// recursive=true, trim=false, follow=false, preserve=false, name="", depth=-1, files=[targetDir]
+	Create(true, false, false, false, "", -1, targetDir)
 
 	w.Close()
 	os.Stdout = oldStdout
@@ -83,7 +83,7 @@ func TestSymlinkVulnerability_Add(t *testing.T) {
 
 	// Run Add on the target directory
 	// recursive=true, follow=false, archive=archivePath, files=[targetDir]
-	Add(true, false, archivePath, targetDir)
+	Add(true, false, "", archivePath, targetDir)
 
 	// Read the archive file
 	data, err := os.ReadFile(archivePath)
@@ -133,8 +133,8 @@ func TestSymlinkFollowing(t *testing.T) {
 	os.Stdout = w
 
 	// Run Create on the directory with follow=true
-	// recursive=true, trim=false, follow=true, name="", depth=-1, files=[archiveDir]
-	Create(true, false, true, "", -1, archiveDir)
+	// recursive=true, trim=false, follow=true, preserve=false, name="", depth=-1, files=[archiveDir]
+	Create(true, false, true, false, "", -1, archiveDir)
 
 	w.Close()
 	os.Stdout = oldStdout
@@ -150,3 +150,35 @@ func TestSymlinkFollowing(t *testing.T) {
 		t.Logf("Symlink followed as expected.")
 	}
 }
+
+func TestSymlinkEntry(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "target.txt"), []byte("TARGET_CONTENT"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target.txt", filepath.Join(tmpDir, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Capture stdout
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// recursive=true, trim=true, follow=false, preserve=false, name="", depth=-1, files=[tmpDir]
+	Create(true, true, false, false, "", -1, tmpDir)
+
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	output := buf.String()
+
+	if !strings.Contains(output, "-- link -> target.txt --\n") {
+		t.Errorf("Create did not record symlink entry:\n%s", output)
+	}
+	if strings.Count(output, "TARGET_CONTENT") != 1 {
+		t.Errorf("Create followed symlink:\n%s", output)
+	}
+}
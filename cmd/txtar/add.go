@@ -19,6 +19,7 @@ type Add struct {
 	Flags         *flag.FlagSet
 	recursive     bool
 	follow        bool
+	before        string
 	archive       string
 	files         []string
 	SubCommands   map[string]Cmd
@@ -91,6 +92,17 @@ func (c *Add) Execute(args []string) error {
 				} else {
 					c.follow = true
 				}
+
+			case "before":
+				if !hasValue {
+					if i+1 < len(args) {
+						value = args[i+1]
+						i++
+					} else {
+						return fmt.Errorf("flag %s requires a value", name)
+					}
+				}
+				c.before = value
 			case "help", "h":
 				c.Usage()
 				return nil
@@ -146,11 +158,13 @@ func (c *RootCmd) NewAdd() *Add {
 
 	set.BoolVar(&v.follow, "follow", false, "Follow symlinks")
 	set.BoolVar(&v.follow, "f", false, "Follow symlinks")
+
+	set.StringVar(&v.before, "before", "", "Insert new files before this file")
 	set.Usage = v.Usage
 
 	v.CommandAction = func(c *Add) error {
 
-		cli.Add(c.recursive, c.follow, c.archive, c.files...)
+		cli.Add(c.recursive, c.follow, c.before, c.archive, c.files...)
 		return nil
 	}
 
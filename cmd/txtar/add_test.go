@@ -24,6 +24,8 @@ func TestAdd_Execute(t *testing.T) {
 	args := []string{}
 	args = append(args, "--recursive")
 	args = append(args, "--follow")
+	args = append(args, "--before")
+	args = append(args, "test")
 	args = append(args, "test")
 
 	err := cmd.Execute(args)
@@ -40,6 +42,9 @@ func TestAdd_Execute(t *testing.T) {
 	if cmd.follow != true {
 		t.Errorf("Expected follow to be true, got '%v'", cmd.follow)
 	}
+	if cmd.before != "test" {
+		t.Errorf("Expected before to be 'test', got '%v'", cmd.before)
+	}
 	if cmd.archive != "test" {
 		t.Errorf("Expected archive to be 'test', got '%v'", cmd.archive)
 	}
@@ -20,6 +20,7 @@ type Create struct {
 	recursive     bool
 	trim          bool
 	follow        bool
+	preserve      bool
 	name          string
 	depth         int
 	files         []string
@@ -105,6 +106,17 @@ func (c *Create) Execute(args []string) error {
 					c.follow = true
 				}
 
+			case "preserve", "p":
+				if hasValue {
+					b, err := strconv.ParseBool(value)
+					if err != nil {
+						return fmt.Errorf("invalid boolean value for flag %s: %s", name, value)
+					}
+					c.preserve = b
+				} else {
+					c.preserve = true
+				}
+
 			case "name":
 				if !hasValue {
 					if i+1 < len(args) {
@@ -178,6 +190,9 @@ func (c *RootCmd) NewCreate() *Create {
 	set.BoolVar(&v.follow, "follow", false, "Follow symlinks")
 	set.BoolVar(&v.follow, "f", false, "Follow symlinks")
 
+	set.BoolVar(&v.preserve, "preserve", false, "Record file mode and modification time")
+	set.BoolVar(&v.preserve, "p", false, "Record file mode and modification time")
+
 	set.StringVar(&v.name, "name", "", "Name filter glob pattern")
 
 	set.IntVar(&v.depth, "depth", -1, "Max depth")
@@ -186,7 +201,7 @@ func (c *RootCmd) NewCreate() *Create {
 
 	v.CommandAction = func(c *Create) error {
 
-		cli.Create(c.recursive, c.trim, c.follow, c.name, c.depth, c.files...)
+		cli.Create(c.recursive, c.trim, c.follow, c.preserve, c.name, c.depth, c.files...)
 		return nil
 	}
 
@@ -25,6 +25,7 @@ func TestCreate_Execute(t *testing.T) {
 	args = append(args, "--recursive")
 	args = append(args, "--trim")
 	args = append(args, "--follow")
+	args = append(args, "--preserve")
 	args = append(args, "--name")
 	args = append(args, "test")
 	args = append(args, "--depth")
@@ -47,6 +48,9 @@ func TestCreate_Execute(t *testing.T) {
 	if cmd.follow != true {
 		t.Errorf("Expected follow to be true, got '%v'", cmd.follow)
 	}
+	if cmd.preserve != true {
+		t.Errorf("Expected preserve to be true, got '%v'", cmd.preserve)
+	}
 	if cmd.name != "test" {
 		t.Errorf("Expected name to be 'test', got '%v'", cmd.name)
 	}
@@ -73,11 +73,18 @@ func (c *RootCmd) UsageRecursive() {
 	fmt.Fprintln(os.Stderr, "  Commands:")
 	fmt.Fprintf(os.Stderr, "    %s\n", "add")
 	fmt.Fprintf(os.Stderr, "    %s\n", "append")
+	fmt.Fprintf(os.Stderr, "    %s\n", "apply-delta")
 	fmt.Fprintf(os.Stderr, "    %s\n", "cat")
 	fmt.Fprintf(os.Stderr, "    %s\n", "comment")
 	fmt.Fprintf(os.Stderr, "    %s\n", "create")
 	fmt.Fprintf(os.Stderr, "    %s\n", "delete")
+	fmt.Fprintf(os.Stderr, "    %s\n", "delta")
+	fmt.Fprintf(os.Stderr, "    %s\n", "diff")
+	fmt.Fprintf(os.Stderr, "    %s\n", "index")
 	fmt.Fprintf(os.Stderr, "    %s\n", "list")
+	fmt.Fprintf(os.Stderr, "    %s\n", "merge")
+	fmt.Fprintf(os.Stderr, "    %s\n", "merge-driver")
+	fmt.Fprintf(os.Stderr, "    %s\n", "patch")
 }
 
 func NewRoot(name, version, commit, date string) (*RootCmd, error) {
@@ -92,11 +99,18 @@ func NewRoot(name, version, commit, date string) (*RootCmd, error) {
 
 	c.Commands["add"] = c.NewAdd()
 	c.Commands["append"] = c.NewAppend()
+	c.Commands["apply-delta"] = c.NewApplyDelta()
 	c.Commands["cat"] = c.NewCat()
 	c.Commands["comment"] = c.NewComment()
 	c.Commands["create"] = c.NewCreate()
 	c.Commands["delete"] = c.NewDelete()
+	c.Commands["delta"] = c.NewDelta()
+	c.Commands["diff"] = c.NewDiff()
+	c.Commands["index"] = c.NewIndex()
 	c.Commands["list"] = c.NewList()
+	c.Commands["merge"] = c.NewMerge()
+	c.Commands["merge-driver"] = c.NewMergeDriver()
+	c.Commands["patch"] = c.NewPatch()
 	c.Commands["help"] = &InternalCommand{
 		Exec: func(args []string) error {
 			for _, arg := range args {
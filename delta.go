@@ -0,0 +1,134 @@
+package txtar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// deltaHeader is the first line of the comment of a delta archive.
+const deltaHeader = "# txtar delta\n"
+
+// ErrNotDelta is returned by ApplyDelta for an archive that was not made by Delta.
+var ErrNotDelta = errors.New("txtar: not a delta archive")
+
+// Delta returns an archive holding the files of new that are not in old
+// or that differ from the file of the same name in old. The archive's
+// comment records the remaining differences, one per line:
+//
+//	# txtar delta
+//	delete "removed.txt"
+//	comment "the new comment\n"
+//	order "a.txt" "b.txt"
+//
+// A delete line names a file of old that is not in new. A comment line is
+// present if the comment changed. An order line, listing every file of new,
+// is present only if applying the delta would otherwise order the files
+// differently from new. Names and text are Go string literals.
+//
+// ApplyDelta applies the delta to old to give new again.
+func Delta(old, new *Archive) *Archive {
+	d := &Archive{}
+	var comment strings.Builder
+	comment.WriteString(deltaHeader)
+	for i, f := range new.Files {
+		if new.Index(f.Name) != i {
+			continue
+		}
+		if g, ok := old.Get(f.Name); !ok || !sameFile(f, g) {
+			d.Files = append(d.Files, f)
+		}
+	}
+	for i, f := range old.Files {
+		if old.Index(f.Name) == i && !new.Has(f.Name) {
+			fmt.Fprintf(&comment, "delete %s\n", strconv.Quote(f.Name))
+		}
+	}
+	if !bytes.Equal(old.Comment, new.Comment) {
+		fmt.Fprintf(&comment, "comment %s\n", strconv.Quote(string(new.Comment)))
+	}
+
+	d.Comment = []byte(comment.String())
+	if got, err := ApplyDelta(old, d); err != nil || !slices.EqualFunc(got.Files, new.Files, func(f, g File) bool {
+		return f.Name == g.Name
+	}) {
+		comment.WriteString("order")
+		for _, f := range new.Files {
+			comment.WriteString(" " + strconv.Quote(f.Name))
+		}
+		comment.WriteString("\n")
+		d.Comment = []byte(comment.String())
+	}
+	return d
+}
+
+// ApplyDelta returns the archive that delta, made by Delta, was made from,
+// given the archive base it was compared with. Base is not modified.
+// ApplyDelta returns an error wrapping ErrNotDelta if delta was not made by
+// Delta, and one wrapping fs.ErrNotExist if a file to delete or order is not
+// in base or delta.
+func ApplyDelta(base, delta *Archive) (*Archive, error) {
+	text, ok := bytes.CutPrefix(delta.Comment, []byte(deltaHeader))
+	if !ok {
+		return nil, ErrNotDelta
+	}
+	a := &Archive{Comment: base.Comment, Files: slices.Clone(base.Files)}
+	var order []string
+	for i, line := range strings.Split(string(text), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		op, args, _ := strings.Cut(line, " ")
+		values, err := parseQuotedList(args)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrNotDelta, i+2, err)
+		}
+		switch {
+		case op == "delete" && len(values) == 1:
+			if !a.Has(values[0]) {
+				return nil, &fs.PathError{Op: "delete", Path: values[0], Err: fs.ErrNotExist}
+			}
+			a.Delete(values[0])
+		case op == "comment" && len(values) == 1:
+			a.Comment = []byte(values[0])
+		case op == "order":
+			order = values
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown directive %q", ErrNotDelta, i+2, line)
+		}
+	}
+	for _, f := range delta.Files {
+		a.SetFile(f)
+	}
+	if order != nil {
+		files := make([]File, 0, len(order))
+		for _, name := range order {
+			f, ok := a.Get(name)
+			if !ok {
+				return nil, &fs.PathError{Op: "order", Path: name, Err: fs.ErrNotExist}
+			}
+			files = append(files, f)
+		}
+		a.Files = files
+	}
+	return a, nil
+}
+
+// parseQuotedList parses a space-separated list of Go string literals.
+func parseQuotedList(s string) ([]string, error) {
+	var values []string
+	for s = strings.TrimLeft(s, " "); s != ""; s = strings.TrimLeft(s, " ") {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return nil, fmt.Errorf("bad quoted string in %q", s)
+		}
+		v, _ := strconv.Unquote(q)
+		values = append(values, v)
+		s = s[len(q):]
+	}
+	return values, nil
+}
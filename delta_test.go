@@ -0,0 +1,76 @@
+package txtar
+
+import (
+	"errors"
+	"io/fs"
+	"reflect"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	old := Parse([]byte("old\n-- a --\n1\n-- b --\n2\n-- c --\n3\n"))
+	tests := []struct {
+		name  string
+		new   string
+		delta string
+	}{
+		{
+			name:  "same",
+			new:   "old\n-- a --\n1\n-- b --\n2\n-- c --\n3\n",
+			delta: "# txtar delta\n",
+		},
+		{
+			name:  "changes",
+			new:   "old\n-- a --\n1\n-- b --\ntwo\n-- d --\n4\n",
+			delta: "# txtar delta\ndelete \"c\"\n-- b --\ntwo\n-- d --\n4\n",
+		},
+		{
+			name:  "comment",
+			new:   "new\n-- a --\n1\n-- b --\n2\n-- c --\n3\n",
+			delta: "# txtar delta\ncomment \"new\\n\"\n",
+		},
+		{
+			name:  "reordered",
+			new:   "old\n-- d --\n4\n-- a --\n1\n-- c --\n3\n-- b --\n2\n",
+			delta: "# txtar delta\norder \"d\" \"a\" \"c\" \"b\"\n-- d --\n4\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			new := Parse([]byte(tt.new))
+			d := Delta(old, new)
+			if got := string(Format(d)); got != tt.delta {
+				t.Errorf("Delta:\n%s\nwant:\n%s", got, tt.delta)
+			}
+			got, err := ApplyDelta(old, Parse(Format(d)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(Format(got)) != tt.new {
+				t.Errorf("ApplyDelta:\n%s\nwant:\n%s", Format(got), tt.new)
+			}
+		})
+	}
+	if want := Parse([]byte("old\n-- a --\n1\n-- b --\n2\n-- c --\n3\n")); !reflect.DeepEqual(old.Files, want.Files) {
+		t.Errorf("base archive modified")
+	}
+}
+
+func TestApplyDeltaErrors(t *testing.T) {
+	base := Parse([]byte("-- a --\n1\n"))
+	tests := []struct {
+		delta string
+		err   error
+	}{
+		{"-- a --\n2\n", ErrNotDelta},
+		{"# txtar delta\nrename \"a\"\n", ErrNotDelta},
+		{"# txtar delta\ndelete a\n", ErrNotDelta},
+		{"# txtar delta\ndelete \"b\"\n", fs.ErrNotExist},
+		{"# txtar delta\norder \"a\" \"b\"\n", fs.ErrNotExist},
+	}
+	for _, tt := range tests {
+		if _, err := ApplyDelta(base, Parse([]byte(tt.delta))); !errors.Is(err, tt.err) {
+			t.Errorf("ApplyDelta(%q) error = %v, want %v", tt.delta, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package txtar
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// A ChangeKind says how an archive entry differs between two archives.
+type ChangeKind string
+
+const (
+	Added          ChangeKind = "added"    // the file is only in the new archive
+	Removed        ChangeKind = "removed"  // the file is only in the old archive
+	Renamed        ChangeKind = "renamed"  // the file has a new name but the same contents
+	Modified       ChangeKind = "modified" // the file's data or attributes changed
+	CommentChanged ChangeKind = "comment"  // the archive comment changed
+)
+
+// A Change is one difference between two archives, as reported by Diff.
+type Change struct {
+	Kind    ChangeKind `json:"kind"`
+	Name    string     `json:"name,omitempty"`     // name of the file in the new archive, or the old one if removed
+	OldName string     `json:"old_name,omitempty"` // previous name of a renamed file
+	Diff    string     `json:"diff,omitempty"`     // unified diff of the file data or comment
+}
+
+// Diff reports the differences between archives a and b.
+//
+// A change to the comment is reported first. The files of b are then
+// reported in order as added, renamed or modified, followed by the files
+// of a that were removed. A file that is only in b is reported as renamed
+// rather than added if a file that is only in a has the same non-empty
+// data and attributes. Only the first file with each name is compared.
+func Diff(a, b *Archive) []Change {
+	var changes []Change
+	if !bytes.Equal(a.Comment, b.Comment) {
+		changes = append(changes, Change{
+			Kind: CommentChanged,
+			Diff: unifiedDiff("a", "b", a.Comment, b.Comment),
+		})
+	}
+
+	// Files only in a that may be the source of a rename.
+	removed := make(map[int]bool)
+	for i, f := range a.Files {
+		if !b.Has(f.Name) && a.Index(f.Name) == i {
+			removed[i] = true
+		}
+	}
+
+	for i, f := range b.Files {
+		if b.Index(f.Name) != i {
+			continue
+		}
+		old, ok := a.Get(f.Name)
+		switch {
+		case !ok:
+			if j := renameSource(a, f, removed); j >= 0 {
+				delete(removed, j)
+				changes = append(changes, Change{Kind: Renamed, Name: f.Name, OldName: a.Files[j].Name})
+				continue
+			}
+			changes = append(changes, Change{
+				Kind: Added,
+				Name: f.Name,
+				Diff: unifiedDiff("/dev/null", "b/"+f.Name, nil, f.Data),
+			})
+		case !sameFile(old, f):
+			changes = append(changes, Change{
+				Kind: Modified,
+				Name: f.Name,
+				Diff: attrDiff(old, f) + unifiedDiff("a/"+f.Name, "b/"+f.Name, old.Data, f.Data),
+			})
+		}
+	}
+
+	for i, f := range a.Files {
+		if removed[i] {
+			changes = append(changes, Change{
+				Kind: Removed,
+				Name: f.Name,
+				Diff: unifiedDiff("a/"+f.Name, "/dev/null", f.Data, nil),
+			})
+		}
+	}
+	return changes
+}
+
+// renameSource returns the index of the first removed file in a with the
+// same contents as f, or -1 if there is none.
+func renameSource(a *Archive, f File, removed map[int]bool) int {
+	if len(f.Data) == 0 {
+		return -1
+	}
+	for i, g := range a.Files {
+		if removed[i] && bytes.Equal(g.Data, f.Data) && g.Mode == f.Mode && g.ModTime.Equal(f.ModTime) {
+			return i
+		}
+	}
+	return -1
+}
+
+// attrDiff describes changes to the mode and modification time of a file.
+func attrDiff(old, new File) string {
+	var s string
+	if old.Mode != new.Mode {
+		s += fmt.Sprintf("old mode %v\nnew mode %v\n", old.Mode, new.Mode)
+	}
+	if !old.ModTime.Equal(new.ModTime) {
+		s += fmt.Sprintf("old mtime %v\nnew mtime %v\n", old.ModTime, new.ModTime)
+	}
+	return s
+}
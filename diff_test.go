@@ -0,0 +1,65 @@
+package txtar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := Parse([]byte(`old comment
+-- keep.txt --
+same
+-- edit.txt --
+one
+two
+-- moved.txt --
+moved content
+-- gone.txt --
+bye
+`))
+	new := Parse([]byte(`new comment
+-- edit.txt --
+one
+2
+-- new/moved.txt --
+moved content
+-- keep.txt --
+same
+-- added.txt --
+hi
+`))
+	want := []Change{
+		{Kind: CommentChanged, Diff: "--- a\n+++ b\n@@ -1 +1 @@\n-old comment\n+new comment\n"},
+		{Kind: Modified, Name: "edit.txt", Diff: "--- a/edit.txt\n+++ b/edit.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+2\n"},
+		{Kind: Renamed, Name: "new/moved.txt", OldName: "moved.txt"},
+		{Kind: Added, Name: "added.txt", Diff: "--- /dev/null\n+++ b/added.txt\n@@ -0,0 +1 @@\n+hi\n"},
+		{Kind: Removed, Name: "gone.txt", Diff: "--- a/gone.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-bye\n"},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff:\nhave %+v\nwant %+v", got, want)
+	}
+	if got := Diff(old, old); len(got) != 0 {
+		t.Errorf("Diff(a, a) = %+v, want none", got)
+	}
+}
+
+func TestDiffReorderOnly(t *testing.T) {
+	a := Parse([]byte("-- a --\n1\n-- b --\n2\n"))
+	b := Parse([]byte("-- b --\n2\n-- a --\n1\n"))
+	if got := Diff(a, b); len(got) != 0 {
+		t.Errorf("Diff of reordered archives = %+v, want none", got)
+	}
+}
+
+func TestDiffAttributes(t *testing.T) {
+	a := Parse([]byte("-- run.sh --\necho\n-- empty --\n"))
+	b := Parse([]byte("-- run.sh mode=0755 --\necho\n-- other --\n"))
+	want := []Change{
+		{Kind: Modified, Name: "run.sh", Diff: "old mode ----------\nnew mode -rwxr-xr-x\n"},
+		{Kind: Added, Name: "other"},
+		{Kind: Removed, Name: "empty"},
+	}
+	if got := Diff(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff:\nhave %+v\nwant %+v", got, want)
+	}
+}
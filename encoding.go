@@ -0,0 +1,261 @@
+package txtar
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"unicode/utf8"
+)
+
+// Flags that follow the file name in the marker line of an encoded file.
+const (
+	quotedFlag = "(quoted)"
+	base64Flag = "(base64)"
+)
+
+// base64LineLen is the length of the lines of base64 encoded data.
+const base64LineLen = 76
+
+// An encoding describes how the data of a file is stored in an archive.
+type encoding int
+
+const (
+	plain         encoding = iota // data stored as is
+	quoted                        // marker-like lines escaped with a leading '>'
+	base64Encoded                 // data stored as base64 lines
+)
+
+// flag returns the marker line flag for e, or "" for plain.
+func (e encoding) flag() string {
+	switch e {
+	case quoted:
+		return quotedFlag
+	case base64Encoded:
+		return base64Flag
+	}
+	return ""
+}
+
+// encodingFor returns the encoding Format uses for data.
+func encodingFor(data []byte) encoding {
+	switch {
+	case isBinary(data):
+		return base64Encoded
+	case needsQuote(data):
+		return quoted
+	}
+	return plain
+}
+
+// encode returns data as it is stored with encoding e.
+func (e encoding) encode(data []byte) []byte {
+	switch e {
+	case quoted:
+		return quote(data)
+	case base64Encoded:
+		return encodeBase64(data)
+	}
+	return data
+}
+
+// encodedLen returns the length of encode(data).
+func (e encoding) encodedLen(data []byte) int {
+	switch e {
+	case quoted:
+		n := len(data)
+		for len(data) > 0 {
+			line := data
+			if i := bytes.IndexByte(data, '\n'); i >= 0 {
+				line = data[:i+1]
+			}
+			data = data[len(line):]
+			if escaped(line) {
+				n++
+			}
+		}
+		return n
+	case base64Encoded:
+		n := base64.StdEncoding.EncodedLen(len(data))
+		return n + (n+base64LineLen-1)/base64LineLen
+	}
+	return len(data)
+}
+
+// decode returns the file data stored as raw.
+// Base64 data that cannot be decoded is returned as is, with a final
+// newline added as for text, so that every way of reading an archive
+// agrees on it whether or not the archive ends in a newline.
+func (e encoding) decode(raw []byte) []byte {
+	switch e {
+	case quoted:
+		return unquote(raw)
+	case base64Encoded:
+		data := make([]byte, base64.StdEncoding.DecodedLen(len(raw)))
+		n, err := base64.StdEncoding.Decode(data, raw)
+		if err != nil {
+			return FixNL(raw)
+		}
+		return data[:n]
+	}
+	return raw
+}
+
+// reader returns a reader for the file data stored in r.
+func (e encoding) reader(r io.Reader) io.Reader {
+	switch e {
+	case quoted:
+		return &unquoteReader{r: bufio.NewReader(r), atStartOfLine: true}
+	case base64Encoded:
+		return &base64Reader{r: r}
+	}
+	return r
+}
+
+// base64Reader decodes base64 file data read from r.
+// Whether the data can be decoded is only known once all of it
+// has been read, so it is decoded as a whole on the first Read,
+// returning data that cannot be decoded as is, like decode.
+type base64Reader struct {
+	r    io.Reader
+	data *bytes.Reader
+}
+
+func (b *base64Reader) Read(p []byte) (int, error) {
+	if b.data == nil {
+		raw, err := io.ReadAll(b.r)
+		if err != nil {
+			return 0, err
+		}
+		b.data = bytes.NewReader(base64Encoded.decode(raw))
+	}
+	return b.data.Read(p)
+}
+
+// isBinary reports whether data is not valid UTF-8 or contains NUL bytes.
+func isBinary(data []byte) bool {
+	return !utf8.Valid(data) || bytes.IndexByte(data, 0) >= 0
+}
+
+// encodeBase64 returns data encoded as base64 lines of base64LineLen characters.
+func encodeBase64(data []byte) []byte {
+	enc := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(enc, data)
+	out := make([]byte, 0, len(enc)+len(enc)/base64LineLen+1)
+	for len(enc) > 0 {
+		n := min(len(enc), base64LineLen)
+		out = append(out, enc[:n]...)
+		out = append(out, '\n')
+		enc = enc[n:]
+	}
+	return out
+}
+
+// needsQuote reports whether data contains a line that
+// would be parsed as a file marker.
+func needsQuote(data []byte) bool {
+	for {
+		if h, _ := isMarker(data); h != nil {
+			return true
+		}
+		i := bytes.Index(data, newlineMarker)
+		if i < 0 {
+			return false
+		}
+		data = data[i+1:]
+	}
+}
+
+// escaped reports whether line begins with zero or more '>' followed by "-- ".
+// Such lines get one extra '>' when quoted.
+func escaped(line []byte) bool {
+	i := 0
+	for i < len(line) && line[i] == '>' {
+		i++
+	}
+	return bytes.HasPrefix(line[i:], marker)
+}
+
+// quote returns a copy of data in which every escaped line has
+// an extra '>' prepended.
+func quote(data []byte) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/32)
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		if escaped(line) {
+			buf.WriteByte('>')
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes()
+}
+
+// unquote reverses quote.
+func unquote(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		line := data
+		if i := bytes.IndexByte(data, '\n'); i >= 0 {
+			line = data[:i+1]
+		}
+		data = data[len(line):]
+		if len(line) > 0 && line[0] == '>' && escaped(line) {
+			line = line[1:]
+		}
+		out = append(out, line...)
+	}
+	return out
+}
+
+// An unquoteReader reverses quote on the data read from r.
+type unquoteReader struct {
+	r             *bufio.Reader
+	atStartOfLine bool
+}
+
+func (u *unquoteReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if u.atStartOfLine {
+		u.atStartOfLine = false
+		if u.escapedLine() {
+			u.r.Discard(1)
+		}
+	}
+	buf, err := u.r.Peek(1)
+	if len(buf) == 0 {
+		return 0, err
+	}
+	buf, _ = u.r.Peek(min(len(p), u.r.Buffered()))
+	if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+		buf = buf[:i+1]
+		u.atStartOfLine = true
+	}
+	n := copy(p, buf)
+	u.r.Discard(n)
+	return n, nil
+}
+
+// escapedLine reports whether the line at the start of the buffer
+// begins with one or more '>' followed by "-- ".
+func (u *unquoteReader) escapedLine() bool {
+	for n := 1; ; n++ {
+		buf, err := u.r.Peek(n)
+		if len(buf) < n || err != nil {
+			return false
+		}
+		if c := buf[n-1]; c != '>' {
+			if n == 1 {
+				return false
+			}
+			buf, _ = u.r.Peek(n + len(marker) - 1)
+			return bytes.HasPrefix(buf[n-1:], marker)
+		}
+	}
+}
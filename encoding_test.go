@@ -0,0 +1,182 @@
+package txtar
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var quoteTests = []struct {
+	name string
+	data string
+}{
+	{"marker", "-- inner.txt --\nhello\n"},
+	{"marker without newline", "before\n-- inner.txt --"},
+	{"crlf marker", "-- inner.txt --\r\nhello\r\n"},
+	{"already escaped", ">-- not a marker\n-- inner --\n>>-- deeper --\n"},
+	{"nested archive", "comment\n-- a --\n-- b (quoted) --\n>-- c --\n"},
+	{"dashes only", "-- foo ---\n-- inner --\n"},
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	for _, tt := range quoteTests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Archive{
+				Comment: []byte("comment\n"),
+				Files: []File{
+					{Name: "golden.txtar", Data: []byte(tt.data)},
+					{Name: "after.txt", Data: []byte("after\n")},
+				},
+			}
+			want := &Archive{Comment: a.Comment, Files: []File{
+				{Name: "golden.txtar", Data: FixNL([]byte(tt.data))},
+				{Name: "after.txt", Data: []byte("after\n")},
+			}}
+			text := Format(a)
+			if !bytes.Contains(text, []byte("-- golden.txtar (quoted) --\n")) {
+				t.Fatalf("Format did not quote file:\n%s", text)
+			}
+
+			if got := Parse(text); !reflect.DeepEqual(got, want) {
+				t.Errorf("Parse: wrong output:\nhave:\n%s\nwant:\n%s", shortArchive(got), shortArchive(want))
+			}
+
+			file := filepath.Join(t.TempDir(), "a.txtar")
+			if err := os.WriteFile(file, text, 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ParseFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("ParseFile: wrong output:\nhave:\n%s\nwant:\n%s", shortArchive(got), shortArchive(want))
+			}
+		})
+	}
+}
+
+func TestQuoteReaderSmallReads(t *testing.T) {
+	data := "-- inner.txt --\n>-- x --\nplain\n"
+	text := Format(&Archive{Files: []File{{Name: "f", Data: []byte(data)}}})
+	r := NewReader(bytes.NewReader(text))
+	if _, err := r.Next(); err != nil {
+		t.Fatal(err)
+	}
+	var got bytes.Buffer
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		got.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got.String() != data {
+		t.Errorf("got %q, want %q", got.String(), data)
+	}
+}
+
+func TestFormatNoQuote(t *testing.T) {
+	a := &Archive{Files: []File{{Name: "f", Data: []byte("-- foo ---\n>-- bar --\n")}}}
+	want := "-- f --\n-- foo ---\n>-- bar --\n"
+	if got := string(Format(a)); got != want {
+		t.Errorf("Format = %q, want %q", got, want)
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	long := bytes.Repeat([]byte{0, 1, 2, 0xff}, 100)
+	for _, data := range [][]byte{png, long, []byte("nul\x00"), []byte("bad utf8 \xc3\x28")} {
+		a := &Archive{Comment: []byte{}, Files: []File{
+			{Name: "logo.png", Data: data},
+			{Name: "after.txt", Data: []byte("after\n")},
+		}}
+		text := Format(a)
+		if !bytes.HasPrefix(text, []byte("-- logo.png (base64) --\n")) {
+			t.Fatalf("Format did not encode binary file:\n%s", text)
+		}
+		for _, line := range bytes.Split(text, []byte("\n")) {
+			if len(line) > base64LineLen {
+				t.Errorf("line too long: %d bytes", len(line))
+			}
+		}
+
+		if got := Parse(text); !reflect.DeepEqual(got, a) {
+			t.Errorf("Parse: wrong output:\nhave:\n%s\nwant:\n%s", shortArchive(got), shortArchive(a))
+		}
+
+		file := filepath.Join(t.TempDir(), "a.txtar")
+		if err := os.WriteFile(file, text, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, a) {
+			t.Errorf("ParseFile: wrong output:\nhave:\n%s\nwant:\n%s", shortArchive(got), shortArchive(a))
+		}
+	}
+}
+
+func TestBase64Corrupt(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		want string // data of file e
+	}{
+		{"-- e (base64) --\nnot base64!\n-- f --\nnext\n", "not base64!\n"},
+		{"-- f --\nnext\n-- e (base64) --\nnot base64!", "not base64!\n"},
+		{"-- f --\nnext\n-- e (base64) --\nAAE=", "\x00\x01"},
+	} {
+		text := []byte(tt.text)
+		a := Parse(text)
+		if f, _ := a.Get("e"); string(f.Data) != tt.want {
+			t.Errorf("Parse(%q): e = %q, want %q", text, f.Data, tt.want)
+		}
+
+		tr := NewReader(bytes.NewReader(text))
+		for _, f := range a.Files {
+			if _, err := tr.Next(); err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("Reader %s: %v", f.Name, err)
+			}
+			if !bytes.Equal(data, f.Data) {
+				t.Errorf("Reader(%q): %s = %q, Parse = %q", text, f.Name, data, f.Data)
+			}
+		}
+
+		file := filepath.Join(t.TempDir(), "a.txtar")
+		if err := os.WriteFile(file, text, 0644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, a) {
+			t.Errorf("ParseFile(%q): wrong output:\nhave:\n%s\nwant:\n%s", text, shortArchive(got), shortArchive(a))
+		}
+		if got := ParseParallel(text, 2); !reflect.DeepEqual(got, a) {
+			t.Errorf("ParseParallel(%q): wrong output:\nhave:\n%s\nwant:\n%s", text, shortArchive(got), shortArchive(a))
+		}
+		fsys, err := OpenIndexed(bytes.NewReader(text))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := fs.ReadFile(fsys, "e"); err != nil || string(data) != tt.want {
+			t.Errorf("OpenIndexed(%q): e = %q, %v, want %q", text, data, err, tt.want)
+		}
+	}
+}
@@ -11,20 +11,23 @@ import (
 	"io/fs"
 	"path"
 	"slices"
+	"strings"
+	"sync"
 	"time"
 )
 
 // FS returns the file system form of an Archive.
 // It returns an error if any of the file names in the archive
 // are not valid file system names.
-// The archive must not be modified while the FS is in use.
+// The archive must not be modified directly while the FS is in use;
+// changes made through the FS itself are safe.
 //
 // If the file system detects that it has been modified, calls to the
 // file system return an ErrModified error.
 func FS(a *Archive) (*FileSystem, error) {
 	// Create a filesystem with a root directory.
 	root := &node{fileinfo: fileinfo{path: ".", mode: readOnlyDir}}
-	fsys := &FileSystem{a, map[string]*node{root.path: root}}
+	fsys := &FileSystem{ar: a, nodes: map[string]*node{root.path: root}}
 
 	if err := initFiles(fsys); err != nil {
 		return nil, fmt.Errorf("cannot create fs.FS from txtar.Archive: %s", err)
@@ -37,6 +40,16 @@ const (
 	readOnlyDir             = readOnly | fs.ModeDir
 )
 
+// ErrLinkEscapes indicates that a symbolic link in the archive
+// points outside of the file system returned by FS.
+var ErrLinkEscapes error = errors.New("symbolic link points outside the archive")
+
+// errTooManyLinks is returned when resolving a path follows too many symbolic links.
+var errTooManyLinks = errors.New("too many levels of symbolic links")
+
+// maxLinks is the maximum number of symbolic links followed when resolving a path.
+const maxLinks = 40
+
 // ErrModified indicates that file system returned by FS
 // noticed that the underlying archive has been modified
 // since the call to FS. Detection of modification is best effort,
@@ -47,42 +60,79 @@ var ErrModified error = errors.New("txtar.Archive has been modified during txtar
 // represented as a map from valid path names to information about the
 // files or directories they represent.
 //
-// File system operations are read only. Modifications to the underlying
-// *Archive may race. To help prevent this, the filesystem tries
-// to detect modification during Open and return ErrModified if it
-// is able to detect a modification.
+// Symbolic links in the archive are followed by Open as long as they
+// point within the file system, or, for a view made by Sub, within the
+// view; links that point outside of it are refused with ErrLinkEscapes.
+// Lstat and ReadLink report the links themselves.
+//
+// The methods of a FileSystem, and of the views returned by Sub, are safe
+// for concurrent use. Create, Remove and Rename change the underlying
+// *Archive; an open file or directory keeps the contents and entries it
+// had when it was opened. Modifying the *Archive directly may race.
+// To help prevent this, the filesystem tries to detect modification
+// during Open and return ErrModified if it is able to detect a modification.
+//
+// Sub returns a FileSystem that is a view of a directory of the
+// FileSystem; changes made through either are seen by both.
+//
+// Create adds new files at the end of the archive, Rename keeps a file
+// in its place and Remove keeps the order of the other files.
 type FileSystem struct {
 	ar    *Archive
 	nodes map[string]*node
+	files []*node      // node of each file in ar.Files, in the same order
+	mu    sync.RWMutex // guards ar, nodes and files
+
+	parent *FileSystem // for a view made by Sub, the file system of the whole archive
+	dir    string      // for a view, the directory of parent it shows
 }
 
 // node is a file or directory in the tree of a filesystem.
 type node struct {
-	fileinfo               // fs.FileInfo and fs.DirEntry implementation
-	idx      int           // index into ar.Files (for files)
-	entries  []fs.DirEntry // subdirectories and files (for directories)
+	fileinfo                  // fs.FileInfo and fs.DirEntry implementation
+	idx      int              // index into ar.Files (for files)
+	link     string           // target (for symbolic links)
+	entries  map[string]*node // subdirectories and files by name (for directories)
 }
 
 var _ fs.FS = (*FileSystem)(nil)
+var _ fs.ReadLinkFS = (*FileSystem)(nil)
+var _ fs.StatFS = (*FileSystem)(nil)
+var _ fs.ReadDirFS = (*FileSystem)(nil)
+var _ fs.GlobFS = (*FileSystem)(nil)
+var _ fs.SubFS = (*FileSystem)(nil)
 var _ fs.DirEntry = (*node)(nil)
 
 // initFiles initializes fsys from fsys.ar.Files. Returns an error if there are any
 // invalid file names or collisions between file or directories.
 func initFiles(fsys *FileSystem) error {
 	for idx, file := range fsys.ar.Files {
-		name := file.Name
-		if !fs.ValidPath(name) {
-			return fmt.Errorf("file %q is an invalid path", name)
+		if !fs.ValidPath(file.Name) {
+			return fmt.Errorf("file %q is an invalid path", file.Name)
 		}
-
-		n := &node{idx: idx, fileinfo: fileinfo{path: name, size: len(file.Data), mode: readOnly}}
+		n := newNode(idx, file)
 		if err := insert(fsys, n); err != nil {
 			return err
 		}
+		fsys.files = append(fsys.files, n)
 	}
 	return nil
 }
 
+// newNode returns the node for the file f at index idx of the archive.
+func newNode(idx int, f File) *node {
+	mode := readOnly
+	if f.Mode != 0 {
+		mode = f.Mode.Perm()
+	}
+	n := &node{idx: idx, fileinfo: fileinfo{path: f.Name, size: len(f.Data), mode: mode, modTime: f.ModTime}}
+	if f.Mode&fs.ModeSymlink != 0 {
+		n.mode = fs.ModeSymlink | readOnly
+		n.link = string(f.Data)
+	}
+	return n
+}
+
 // insert adds node n as an entry to its parent directory within the filesystem.
 func insert(fsys *FileSystem, n *node) error {
 	if m := fsys.nodes[n.path]; m != nil {
@@ -95,7 +145,10 @@ func insert(fsys *FileSystem, n *node) error {
 	if err != nil {
 		return err
 	}
-	parent.entries = append(parent.entries, n)
+	if parent.entries == nil {
+		parent.entries = make(map[string]*node)
+	}
+	parent.entries[n.Name()] = n
 	return nil
 }
 
@@ -127,24 +180,106 @@ func dataOf(fsys *FileSystem, n *node) ([]byte, error) {
 	return f.Data, nil
 }
 
+// resolve returns the node for name, following symbolic links in its
+// directory elements, and in its final element if follow is set.
+// name is relative to the directory root, and links that point
+// outside of root are refused.
+func resolve(fsys *FileSystem, op, root, name string, follow bool) (*node, error) {
+	links := 0
+	dir, rest := root, name
+	for rest != "." {
+		elem, after, _ := strings.Cut(rest, "/")
+		p := path.Join(dir, elem)
+		n := fsys.nodes[p]
+		if n == nil {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		if n.link != "" && (after != "" || follow) {
+			if links++; links > maxLinks {
+				return nil, &fs.PathError{Op: op, Path: name, Err: errTooManyLinks}
+			}
+			target, ok := within(root, path.Join(dir, n.link))
+			if path.IsAbs(n.link) || !ok {
+				return nil, &fs.PathError{Op: op, Path: name, Err: ErrLinkEscapes}
+			}
+			dir, rest = root, path.Join(target, after)
+			continue
+		}
+		if after == "" {
+			return n, nil
+		}
+		if !n.IsDir() {
+			return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+		}
+		dir, rest = p, after
+	}
+	if n := fsys.nodes[dir]; n != nil {
+		return n, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// within returns p relative to root, reporting false if p is not in root.
+func within(root, p string) (string, bool) {
+	if root == "." {
+		return p, p != ".." && !strings.HasPrefix(p, "../")
+	}
+	if p == root {
+		return ".", true
+	}
+	rel, ok := strings.CutPrefix(p, root+"/")
+	return rel, ok
+}
+
+// lookup returns the node for name in fsys, which may be a view made by
+// Sub, as resolve does. Symbolic links are only followed within the view.
+// It also returns the file system of the whole archive, which holds the
+// node and whose lock the caller must hold.
+func (fsys *FileSystem) lookup(op, name string, follow bool) (*FileSystem, *node, error) {
+	if fsys.parent == nil {
+		n, err := resolve(fsys, op, ".", name, follow)
+		return fsys, n, err
+	}
+	top := fsys.parent
+	root, err := resolve(top, op, ".", fsys.dir, true)
+	if err != nil || !root.IsDir() {
+		return top, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	n, err := resolve(top, op, root.path, name, follow)
+	return top, n, err
+}
+
+// rlock read-locks the file system of the whole archive fsys belongs to,
+// and returns the function to unlock it.
+func (fsys *FileSystem) rlock() func() {
+	top := fsys
+	if fsys.parent != nil {
+		top = fsys.parent
+	}
+	top.mu.RLock()
+	return top.mu.RUnlock
+}
+
 func (fsys *FileSystem) Open(name string) (fs.File, error) {
 	if !fs.ValidPath(name) {
 		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
 	}
+	defer fsys.rlock()()
 
-	n := fsys.nodes[name]
-	switch {
-	case n == nil:
-		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
-	case n.IsDir():
-		return &openDir{fileinfo: n.fileinfo, entries: n.entries}, nil
-	default:
-		data, err := dataOf(fsys, n)
-		if err != nil {
-			return nil, err
-		}
-		return &openFile{fileinfo: n.fileinfo, data: data}, nil
+	top, n, err := fsys.lookup("open", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := n.fileinfo
+	info.path = path.Join(fsys.dir, name) // the name opened, not a link's target
+	if n.IsDir() {
+		return &openDir{fileinfo: info, entries: sortedEntries(n)}, nil
+	}
+	data, err := dataOf(top, n)
+	if err != nil {
+		return nil, err
 	}
+	return &openFile{fileinfo: info, data: data}, nil
 }
 
 func (fsys *FileSystem) ReadFile(name string) ([]byte, error) {
@@ -158,10 +293,143 @@ func (fsys *FileSystem) ReadFile(name string) ([]byte, error) {
 	return nil, &fs.PathError{Op: "read", Path: name, Err: fs.ErrInvalid}
 }
 
+// ReadLink returns the target of the named symbolic link.
+func (fsys *FileSystem) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	defer fsys.rlock()()
+	top, n, err := fsys.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.link == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	data, err := dataOf(top, n)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Lstat returns a FileInfo describing the named file.
+// If the file is a symbolic link, it describes the link itself.
+func (fsys *FileSystem) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	defer fsys.rlock()()
+	_, n, err := fsys.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info := n.fileinfo
+	info.path = path.Join(fsys.dir, name)
+	return &info, nil
+}
+
+// Stat returns a FileInfo describing the named file,
+// following symbolic links. Its Name is that of the named file,
+// not of the link's target.
+func (fsys *FileSystem) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	defer fsys.rlock()()
+	_, n, err := fsys.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := n.fileinfo
+	info.path = path.Join(fsys.dir, name)
+	return &info, nil
+}
+
+// ReadDir reads the named directory
+// and returns a list of directory entries sorted by filename.
+func (fsys *FileSystem) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	defer fsys.rlock()()
+	_, n, err := fsys.lookup("readdir", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+	return sortedEntries(n), nil
+}
+
+// errNotDir is returned when a directory operation is used on a file.
+var errNotDir = errors.New("not a directory")
+
+// sortedEntries returns a snapshot of the entries of the directory n,
+// sorted by name, that later changes to the nodes do not affect.
+func sortedEntries(n *node) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.entries))
+	for _, e := range n.entries {
+		info := e.fileinfo
+		entries = append(entries, &info)
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries
+}
+
+// Glob returns the names of all files matching pattern,
+// with the same results as fs.Glob.
+func (fsys *FileSystem) Glob(pattern string) ([]string, error) {
+	return fs.Glob(globFS{fsys}, pattern)
+}
+
+// globFS gives fs.Glob the methods of a FileSystem other than Glob,
+// which would call fs.Glob again.
+type globFS struct{ fsys *FileSystem }
+
+func (g globFS) Open(name string) (fs.File, error)          { return g.fsys.Open(name) }
+func (g globFS) Stat(name string) (fs.FileInfo, error)      { return g.fsys.Stat(name) }
+func (g globFS) ReadDir(name string) ([]fs.DirEntry, error) { return g.fsys.ReadDir(name) }
+
+// Sub returns a *FileSystem corresponding to the subtree rooted at dir.
+// The directory need not exist yet. Files created, removed or renamed
+// through the returned FileSystem change the same archive as fsys.
+func (fsys *FileSystem) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return fsys, nil
+	}
+	if fsys.parent != nil {
+		return &FileSystem{ar: fsys.ar, parent: fsys.parent, dir: path.Join(fsys.dir, dir)}, nil
+	}
+	return &FileSystem{ar: fsys.ar, parent: fsys, dir: dir}, nil
+}
+
+// fixErr makes the path in an error returned by fsys.parent
+// relative to the directory of the view fsys.
+func (fsys *FileSystem) fixErr(err error) error {
+	if e, ok := err.(*fs.PathError); ok && fsys.parent != nil {
+		if rel, ok := strings.CutPrefix(e.Path, fsys.dir+"/"); ok {
+			e.Path = rel
+		} else if e.Path == fsys.dir {
+			e.Path = "."
+		}
+	}
+	return err
+}
+
 func (fsys *FileSystem) Create(name string) (io.WriteCloser, error) {
 	if !fs.ValidPath(name) {
 		return nil, &fs.PathError{Op: "create", Path: name, Err: fs.ErrInvalid}
 	}
+	if fsys.parent != nil {
+		return fsys.parent.Create(path.Join(fsys.dir, name))
+	}
 	return &fileWriter{fsys: fsys, name: name}, nil
 }
 
@@ -177,45 +445,148 @@ func (w *fileWriter) Write(p []byte) (int, error) {
 }
 
 func (w *fileWriter) Close() error {
-	w.fsys.ar.Set(w.name, w.buf)
-	return w.fsys.reload()
+	w.fsys.mu.Lock()
+	defer w.fsys.mu.Unlock()
+	return w.fsys.setFile("create", File{Name: w.name, Data: w.buf})
 }
 
-func (fsys *FileSystem) reload() error {
-	root := &node{fileinfo: fileinfo{path: ".", mode: readOnlyDir}}
-	fsys.nodes = map[string]*node{root.path: root}
-	return initFiles(fsys)
+// errIsDir is returned when a file operation is used on a directory.
+var errIsDir = errors.New("is a directory")
+
+// setFile sets f in the archive, replacing the file with the same name
+// if there is one, and updates the nodes to match. It returns an error,
+// leaving the archive unchanged, if f.Name or one of its parent
+// directories is already in use.
+func (fsys *FileSystem) setFile(op string, f File) error {
+	if n := fsys.nodes[f.Name]; n != nil {
+		if n.IsDir() {
+			return &fs.PathError{Op: op, Path: f.Name, Err: errIsDir}
+		}
+		if _, err := dataOf(fsys, n); err != nil {
+			return err
+		}
+		fsys.ar.Files[n.idx] = f
+		*n = *newNode(n.idx, f) // parent entries point at n
+		return nil
+	}
+	if err := fsys.checkDirs(op, f.Name); err != nil {
+		return err
+	}
+	n := newNode(len(fsys.ar.Files), f)
+	if err := insert(fsys, n); err != nil {
+		return err
+	}
+	fsys.ar.Files = append(fsys.ar.Files, f)
+	fsys.files = append(fsys.files, n)
+	return nil
+}
+
+// checkDirs returns an error if one of the parent directories
+// of name is in use by a file.
+func (fsys *FileSystem) checkDirs(op, name string) error {
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if m := fsys.nodes[dir]; m != nil {
+			if !m.IsDir() {
+				return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// removeFile removes the file of node n from the archive, moving the
+// later files down, and unlinks n from the tree.
+func (fsys *FileSystem) removeFile(n *node) {
+	fsys.ar.Files = slices.Delete(fsys.ar.Files, n.idx, n.idx+1)
+	fsys.files = slices.Delete(fsys.files, n.idx, n.idx+1)
+	for i := n.idx; i < len(fsys.files); i++ {
+		fsys.files[i].idx = i
+	}
+	fsys.unlink(n)
+}
+
+// unlink removes n from the tree, along with any directories
+// that are left empty.
+func (fsys *FileSystem) unlink(n *node) {
+	delete(fsys.nodes, n.path)
+	for n.path != "." {
+		parent := fsys.nodes[path.Dir(n.path)]
+		delete(parent.entries, n.Name())
+		if len(parent.entries) > 0 || parent.path == "." {
+			break
+		}
+		delete(fsys.nodes, parent.path)
+		n = parent
+	}
 }
 
 func (fsys *FileSystem) Remove(name string) error {
 	if !fs.ValidPath(name) {
 		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
 	}
-	fsys.ar.Delete(name)
-	return fsys.reload()
+	if fsys.parent != nil {
+		return fsys.fixErr(fsys.parent.Remove(path.Join(fsys.dir, name)))
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if n := fsys.nodes[name]; n != nil && !n.IsDir() {
+		if _, err := dataOf(fsys, n); err != nil {
+			return err
+		}
+		fsys.removeFile(n)
+	}
+	return nil
 }
 
 func (fsys *FileSystem) Rename(oldName, newName string) error {
 	if !fs.ValidPath(oldName) || !fs.ValidPath(newName) {
 		return &fs.PathError{Op: "rename", Path: oldName + "->" + newName, Err: fs.ErrInvalid}
 	}
+	if fsys.parent != nil {
+		return fsys.fixErr(fsys.parent.Rename(path.Join(fsys.dir, oldName), path.Join(fsys.dir, newName)))
+	}
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
 
-	data, err := fsys.ReadFile(oldName)
-	if err != nil {
+	n := fsys.nodes[oldName]
+	switch {
+	case n == nil:
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrNotExist}
+	case n.IsDir():
+		return &fs.PathError{Op: "rename", Path: oldName, Err: fs.ErrInvalid}
+	}
+	if _, err := dataOf(fsys, n); err != nil {
 		return err
 	}
+	if oldName == newName {
+		return nil
+	}
 
-	fsys.ar.Set(newName, data)
-	fsys.ar.Delete(oldName)
-
-	return fsys.reload()
+	if fsys.nodes[newName] != nil {
+		f := fsys.ar.Files[n.idx]
+		f.Name = newName
+		if err := fsys.setFile("rename", f); err != nil {
+			return err
+		}
+		fsys.removeFile(n)
+		return nil
+	}
+	if err := fsys.checkDirs("rename", newName); err != nil {
+		return err
+	}
+	fsys.ar.Files[n.idx].Name = newName
+	fsys.unlink(n)
+	n.path = newName
+	return insert(fsys, n)
 }
 
 // A fileinfo implements fs.FileInfo and fs.DirEntry for a given archive file.
 type fileinfo struct {
-	path string // unique path to the file or directory within a filesystem
-	size int
-	mode fs.FileMode
+	path    string // unique path to the file or directory within a filesystem
+	size    int
+	mode    fs.FileMode
+	modTime time.Time
 }
 
 var _ fs.FileInfo = (*fileinfo)(nil)
@@ -225,7 +596,7 @@ func (i *fileinfo) Name() string               { return path.Base(i.path) }
 func (i *fileinfo) Size() int64                { return int64(i.size) }
 func (i *fileinfo) Mode() fs.FileMode          { return i.mode }
 func (i *fileinfo) Type() fs.FileMode          { return i.mode.Type() }
-func (i *fileinfo) ModTime() time.Time         { return time.Time{} }
+func (i *fileinfo) ModTime() time.Time         { return i.modTime }
 func (i *fileinfo) IsDir() bool                { return i.mode&fs.ModeDir != 0 }
 func (i *fileinfo) Sys() any                   { return nil }
 func (i *fileinfo) Info() (fs.FileInfo, error) { return i, nil }
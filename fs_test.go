@@ -5,10 +5,15 @@
 package txtar_test
 
 import (
+	"errors"
 	"io/fs"
+	"math/rand/v2"
+	"path"
+	"slices"
 	"strings"
 	"testing"
 	"testing/fstest"
+	"time"
 
 	"txtar"
 )
@@ -181,3 +186,288 @@ one
 		t.Errorf("ReadFile(%q) = %q; want %q", "1/one.txt", got, want)
 	}
 }
+
+func TestFileAttributes(t *testing.T) {
+	const input = `
+-- run.sh mode=0755 mtime=2024-01-02T15:04:05Z --
+echo hi
+-- plain.txt --
+plain
+`
+	a := txtar.Parse([]byte(input))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := fs.Stat(fsys, "run.sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Mode(), fs.FileMode(0o755); got != want {
+		t.Errorf("Mode() = %v, want %v", got, want)
+	}
+	if got, want := info.ModTime(), time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ModTime() = %v, want %v", got, want)
+	}
+
+	info, err = fs.Stat(fsys, "plain.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := info.Mode(), fs.FileMode(0o444); got != want {
+		t.Errorf("Mode() = %v, want %v", got, want)
+	}
+	if !info.ModTime().IsZero() {
+		t.Errorf("ModTime() = %v, want zero time", info.ModTime())
+	}
+}
+
+func TestSymlinks(t *testing.T) {
+	const input = `
+-- dir/file.txt --
+content
+-- dir/link.txt -> file.txt --
+-- dirlink -> dir --
+-- up/link -> ../dir/file.txt --
+-- chain -> dirlink/link.txt --
+-- escape -> ../outside --
+-- absolute -> /etc/passwd --
+-- loop1 -> loop2 --
+-- loop2 -> loop1 --
+`
+	a := txtar.Parse([]byte(input))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"dir/link.txt", "dirlink/file.txt", "dirlink/link.txt", "up/link", "chain"} {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Errorf("ReadFile(%q): %v", name, err)
+			continue
+		}
+		if string(data) != "content\n" {
+			t.Errorf("ReadFile(%q) = %q, want %q", name, data, "content\n")
+		}
+	}
+
+	for _, name := range []string{"escape", "absolute"} {
+		if _, err := fsys.Open(name); !errors.Is(err, txtar.ErrLinkEscapes) {
+			t.Errorf("Open(%q) error = %v, want ErrLinkEscapes", name, err)
+		}
+	}
+	if _, err := fsys.Open("loop1"); err == nil {
+		t.Error("Open(loop1) succeeded, want error")
+	}
+
+	target, err := fs.ReadLink(fsys, "dirlink")
+	if err != nil || target != "dir" {
+		t.Errorf("ReadLink(dirlink) = %q, %v; want %q", target, err, "dir")
+	}
+	if _, err := fs.ReadLink(fsys, "dir/file.txt"); err == nil {
+		t.Error("ReadLink(dir/file.txt) succeeded on a regular file")
+	}
+
+	info, err := fs.Lstat(fsys, "dirlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("Lstat(dirlink).Mode() = %v, want symlink", info.Mode())
+	}
+	info, err = fs.Stat(fsys, "dirlink")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Errorf("Stat(dirlink).Mode() = %v, want directory", info.Mode())
+	}
+}
+
+func TestSymlinkNames(t *testing.T) {
+	a := txtar.Parse([]byte("-- dir/file.txt --\ncontent\n-- dir/link.txt -> file.txt --\n-- dirlink -> dir --\n"))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexed, err := txtar.OpenIndexed(strings.NewReader(string(txtar.Format(a))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fsys := range []fs.FS{fsys, indexed} {
+		for _, name := range []string{"dir/link.txt", "dirlink", "dirlink/link.txt"} {
+			info, err := fs.Stat(fsys, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := path.Base(name); info.Name() != want {
+				t.Errorf("%T: Stat(%q).Name() = %q, want %q", fsys, name, info.Name(), want)
+			}
+		}
+	}
+}
+
+func TestSubSymlinks(t *testing.T) {
+	const input = `
+-- top.txt --
+top
+-- dir/file.txt --
+content
+-- dir/in -> file.txt --
+-- dir/up -> ../top.txt --
+-- dir/upin -> ../dir/file.txt --
+-- alias -> dir --
+`
+	fsys, err := txtar.FS(txtar.Parse([]byte(input)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := fs.ReadFile(fsys, "dir/up"); err != nil || string(data) != "top\n" {
+		t.Errorf("ReadFile(dir/up) = %q, %v", data, err)
+	}
+	for _, dir := range []string{"dir", "alias"} {
+		sub, err := fs.Sub(fsys, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"in", "upin"} {
+			if data, err := fs.ReadFile(sub, name); err != nil || string(data) != "content\n" {
+				t.Errorf("Sub(%s): ReadFile(%q) = %q, %v", dir, name, data, err)
+			}
+		}
+		if _, err := fs.ReadFile(sub, "up"); !errors.Is(err, txtar.ErrLinkEscapes) {
+			t.Errorf("Sub(%s): ReadFile(up) error = %v, want ErrLinkEscapes", dir, err)
+		}
+		if _, err := fs.Stat(sub, "up"); !errors.Is(err, txtar.ErrLinkEscapes) {
+			t.Errorf("Sub(%s): Stat(up) error = %v, want ErrLinkEscapes", dir, err)
+		}
+		if info, err := fs.Lstat(sub, "up"); err != nil || info.Name() != "up" {
+			t.Errorf("Sub(%s): Lstat(up) = %v, %v", dir, info, err)
+		}
+	}
+}
+
+func TestSymlinksTestFS(t *testing.T) {
+	const input = `
+-- dir/file.txt --
+content
+-- dir/link.txt -> file.txt --
+-- dirlink -> dir --
+-- up/link -> ../dir/file.txt --
+`
+	a := txtar.Parse([]byte(input))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "dir/file.txt", "dir/link.txt", "dirlink", "up/link"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFSManyArchives(t *testing.T) {
+	elems := []string{"a", "b", "c.txt", "dir", "z"}
+	r := rand.New(rand.NewPCG(3, 4))
+	for i := range 200 {
+		a := new(txtar.Archive)
+		var files []string
+		used := map[string]bool{} // names of files and their parent directories
+		for range r.IntN(12) {
+			parts := make([]string, 1+r.IntN(3))
+			for j := range parts {
+				parts[j] = elems[r.IntN(len(elems))]
+			}
+			name := path.Join(parts...)
+			if used[name] || slices.ContainsFunc(files, func(f string) bool {
+				return strings.HasPrefix(name, f+"/")
+			}) {
+				continue
+			}
+			for d := path.Dir(name); d != "."; d = path.Dir(d) {
+				used[d] = true
+			}
+			used[name] = true
+			files = append(files, name)
+			// Links only point within their top-level directory,
+			// which is all that fs.Sub views of it can follow.
+			top, _, nested := strings.Cut(name, "/")
+			targets := slices.DeleteFunc(slices.Clone(a.Files), func(f txtar.File) bool {
+				return nested && !strings.HasPrefix(f.Name, top+"/")
+			})
+			if len(targets) > 0 && r.IntN(4) == 0 {
+				target := targets[r.IntN(len(targets))].Name
+				rel := strings.Repeat("../", strings.Count(name, "/")) + target
+				a.Files = append(a.Files, txtar.File{Name: name, Data: []byte(rel), Mode: fs.ModeSymlink})
+				continue
+			}
+			a.Files = append(a.Files, txtar.File{Name: name, Data: []byte(name + "\n")})
+		}
+
+		fsys, err := txtar.FS(a)
+		if err != nil {
+			t.Fatalf("archive %d: %v\n%s", i, err, txtar.Format(a))
+		}
+		if err := fstest.TestFS(fsys, files...); err != nil {
+			t.Fatalf("archive %d:\n%s\n%v", i, txtar.Format(a), err)
+		}
+	}
+}
+
+func TestSub(t *testing.T) {
+	a := txtar.Parse([]byte("-- top.txt --\ntop\n-- dir/one.txt --\none\n-- dir/sub/two.txt --\ntwo\n"))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sfs, err := fs.Sub(fsys, "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, ok := sfs.(*txtar.FileSystem)
+	if !ok {
+		t.Fatalf("Sub returned %T, want *txtar.FileSystem", sfs)
+	}
+	if err := fstest.TestFS(sub, "one.txt", "sub/two.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if matches, err := fs.Glob(sub, "*/*.txt"); err != nil || !slices.Equal(matches, []string{"sub/two.txt"}) {
+		t.Errorf("Glob(sub, */*.txt) = %q, %v", matches, err)
+	}
+
+	w, err := sub.Create("new.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("new\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Rename("one.txt", "sub/one.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Remove("sub/two.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(a), "top.txt dir/sub/one.txt dir/new.txt"; got != want {
+		t.Errorf("archive files after changes through Sub = %q, want %q", got, want)
+	}
+	if data, err := fs.ReadFile(fsys, "dir/new.txt"); err != nil || string(data) != "new\n" {
+		t.Errorf("ReadFile(fsys, dir/new.txt) = %q, %v", data, err)
+	}
+
+	_, err = sub.Open("missing")
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe.Path != "missing" {
+		t.Errorf("Open(missing) in Sub: err = %v, want a PathError for %q", err, "missing")
+	}
+}
+
+func names(a *txtar.Archive) string {
+	var list []string
+	for _, f := range a.Files {
+		list = append(list, f.Name)
+	}
+	return strings.Join(list, " ")
+}
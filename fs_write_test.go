@@ -2,7 +2,13 @@ package txtar_test
 
 import (
 	"errors"
+	"fmt"
+	"io"
 	"io/fs"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"txtar"
 )
@@ -178,3 +184,322 @@ func TestFileSystemRename(t *testing.T) {
 		t.Error("Rename(nonexistent.txt, baz.txt) succeeded, want error")
 	}
 }
+
+func TestFileSystemUpdates(t *testing.T) {
+	names := []string{"a", "b.txt", "d/a", "d/b.txt", "d/e/a", "d/e/f/a", "g/a", "d"}
+	r := rand.New(rand.NewPCG(5, 6))
+	a := new(txtar.Archive)
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 2000 {
+		name := names[r.IntN(len(names))]
+		var op string
+		switch r.IntN(3) {
+		case 0:
+			op = "create " + name
+			w, err := fsys.Create(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintf(w, "%d\n", i)
+			w.Close()
+		case 1:
+			op = "remove " + name
+			fsys.Remove(name)
+		case 2:
+			to := names[r.IntN(len(names))]
+			op = "rename " + name + " " + to
+			fsys.Rename(name, to)
+		}
+
+		want, err := txtar.FS(a)
+		if err != nil {
+			t.Fatalf("step %d, %s: archive is no longer valid: %v\n%s", i, op, err, txtar.Format(a))
+		}
+		if got, want := walk(t, fsys), walk(t, want); got != want {
+			t.Fatalf("step %d, %s: file system is\n%s\nwant\n%s", i, op, got, want)
+		}
+	}
+}
+
+func TestFileSystemOrder(t *testing.T) {
+	a := txtar.Parse([]byte("-- a --\na\n-- b --\nb\n-- c --\nc\n-- d --\nd\n"))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("b", "dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(a), "a dir/b c d"; got != want {
+		t.Errorf("after Rename(b, dir/b), archive files = %q, want %q", got, want)
+	}
+	if err := fsys.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(a), "dir/b c d"; got != want {
+		t.Errorf("after Remove(a), archive files = %q, want %q", got, want)
+	}
+	if err := fsys.Rename("c", "dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := names(a), "dir/b d"; got != want {
+		t.Errorf("after Rename(c, dir/b), archive files = %q, want %q", got, want)
+	}
+	if data, err := fs.ReadFile(fsys, "dir/b"); err != nil || string(data) != "c\n" {
+		t.Errorf("ReadFile(dir/b) = %q, %v, want %q", data, err, "c\n")
+	}
+	want, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := walk(t, fsys), walk(t, want); got != want {
+		t.Errorf("file system is\n%s\nwant\n%s", got, want)
+	}
+
+	// Changes made directly to the archive leave the nodes
+	// pointing at the wrong files; writes must not trust them.
+	a.Files[0], a.Files[1] = a.Files[1], a.Files[0]
+	if err := fsys.Remove("d"); !errors.Is(err, txtar.ErrModified) {
+		t.Errorf("Remove(d) after modifying the archive: %v, want ErrModified", err)
+	}
+	w, err := fsys.Create("dir/b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("new\n"))
+	if err := w.Close(); !errors.Is(err, txtar.ErrModified) {
+		t.Errorf("Create(dir/b) after modifying the archive: %v, want ErrModified", err)
+	}
+	if got, want := names(a), "d dir/b"; got != want {
+		t.Errorf("archive files = %q, want %q", got, want)
+	}
+}
+
+// walk returns a listing of fsys with the contents of its files.
+func walk(t *testing.T, fsys fs.FS) string {
+	var b strings.Builder
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "%s %v\n", name, d.Type())
+		if !d.IsDir() {
+			data, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(&b, "\t%q\n", data)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func BenchmarkFileSystemCreate(b *testing.B) {
+	const files = 100000
+	names := make([]string, files)
+	for i := range names {
+		names[i] = fmt.Sprintf("dir%d/file%d.txt", i%100, i)
+	}
+	data := []byte("content\n")
+	write := func(fsys *txtar.FileSystem) {
+		for _, name := range names {
+			w, err := fsys.Create(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			w.Write(data)
+			if err := w.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("new", func(b *testing.B) {
+		for range b.N {
+			fsys, err := txtar.FS(new(txtar.Archive))
+			if err != nil {
+				b.Fatal(err)
+			}
+			write(fsys)
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*files), "ns/file")
+	})
+	b.Run("overwrite", func(b *testing.B) {
+		fsys, err := txtar.FS(new(txtar.Archive))
+		if err != nil {
+			b.Fatal(err)
+		}
+		write(fsys)
+		b.ResetTimer()
+		for range b.N {
+			write(fsys)
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*files), "ns/file")
+	})
+}
+
+// benchFS returns a file system over an archive of n files spread
+// over 100 directories, and the names of the files.
+func benchFS(b *testing.B, n int) (*txtar.FileSystem, []string) {
+	a := new(txtar.Archive)
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("dir%d/file%d.txt", i%100, i)
+		a.Files = append(a.Files, txtar.File{Name: names[i], Data: []byte("content\n")})
+	}
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return fsys, names
+}
+
+// BenchmarkFileSystemRemove removes files from an archive of 100k files.
+// Removing a file moves the files after it down, so "spread" removes
+// every 100th file and "last" removes all files from the end.
+func BenchmarkFileSystemRemove(b *testing.B) {
+	const files = 100000
+	remove := func(b *testing.B, order func([]string) []string) {
+		removed := 0
+		for range b.N {
+			b.StopTimer()
+			fsys, names := benchFS(b, files)
+			names = order(names)
+			b.StartTimer()
+			for _, name := range names {
+				if err := fsys.Remove(name); err != nil {
+					b.Fatal(err)
+				}
+			}
+			removed += len(names)
+		}
+		b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(removed), "ns/file")
+	}
+
+	b.Run("spread", func(b *testing.B) {
+		remove(b, func(names []string) []string {
+			var spread []string
+			for i := 0; i < len(names); i += 100 {
+				spread = append(spread, names[i])
+			}
+			return spread
+		})
+	})
+	b.Run("last", func(b *testing.B) {
+		remove(b, func(names []string) []string {
+			slices.Reverse(names)
+			return names
+		})
+	})
+}
+
+func BenchmarkFileSystemRename(b *testing.B) {
+	const files = 100000
+	fsys, names := benchFS(b, files)
+	renamed := make([]string, files)
+	for i, name := range names {
+		renamed[i] = "renamed/" + name
+	}
+	b.ResetTimer()
+	for range b.N {
+		for i := range names {
+			if err := fsys.Rename(names[i], renamed[i]); err != nil {
+				b.Fatal(err)
+			}
+		}
+		names, renamed = renamed, names
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*files), "ns/file")
+}
+
+func TestFileSystemConcurrent(t *testing.T) {
+	a := txtar.Parse([]byte("-- fixtures/a.txt --\na\n-- fixtures/b.txt --\nb\n"))
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := fsys.Sub("out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := sub.(*txtar.FileSystem)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		defer close(done)
+		for i := range 500 {
+			name := fmt.Sprintf("dir%d/out%d.txt", i%5, i%20)
+			w, err := out.Create(name)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			fmt.Fprintf(w, "output %d\n", i)
+			if err := w.Close(); err != nil {
+				t.Error(err)
+				return
+			}
+			switch i % 3 {
+			case 1:
+				out.Remove(name)
+			case 2:
+				out.Rename(name, fmt.Sprintf("renamed%d.txt", i%7))
+			}
+		}
+	})
+	for range 4 {
+		wg.Go(func() {
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if data, err := fs.ReadFile(fsys, "fixtures/a.txt"); err != nil || string(data) != "a\n" {
+					t.Errorf("ReadFile(fixtures/a.txt) = %q, %v", data, err)
+					return
+				}
+				// Files may disappear between listing and reading them,
+				// but whatever is read must be complete.
+				fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+					if err != nil || d.IsDir() {
+						return nil
+					}
+					f, err := fsys.Open(name)
+					if err != nil {
+						return nil
+					}
+					defer f.Close()
+					info, err := f.Stat()
+					if err != nil {
+						t.Error(err)
+						return nil
+					}
+					data, err := io.ReadAll(f)
+					if err != nil || int64(len(data)) != info.Size() {
+						t.Errorf("%s: read %q, %v; want %d bytes", name, data, err, info.Size())
+					}
+					return nil
+				})
+				if _, err := fsys.ReadDir("fixtures"); err != nil {
+					t.Error(err)
+				}
+				fsys.Stat("out/renamed1.txt")
+				fs.Glob(fsys, "out/*/*.txt")
+			}
+		})
+	}
+	wg.Wait()
+
+	if _, err := txtar.FS(a); err != nil {
+		t.Errorf("archive is not valid after concurrent use: %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package txtar
+
+import (
+	"runtime"
+	"slices"
+	"sync"
+	"unsafe"
+	"weak"
+)
+
+// A nameIndex maps file names to their position in an Archive's Files.
+//
+// Files is exported and may be changed directly, so the index records
+// enough of the slice it was built from to notice most such changes:
+// a different backing array, length or capacity, or a different last
+// file. Lookups also check that the file found has the name asked for.
+// An index that fails these checks is rebuilt.
+type nameIndex struct {
+	names map[string]int // index of the first file with each name
+	data  *File          // unsafe.SliceData(Files) when the index was last updated
+	n, c  int            // len(Files) and cap(Files) when the index was last updated
+	last  string         // name of the last file when the index was last updated
+	dups  bool           // whether some name appears more than once
+}
+
+// indexes holds the name index of each Archive that has one, keyed by
+// a weak pointer to the Archive. It is kept outside of Archive so that
+// looking up a file does not make two equal archives differ under
+// reflect.DeepEqual.
+var indexes sync.Map // weak.Pointer[Archive] -> *nameIndex
+
+// valid reports whether x still describes files.
+func (x *nameIndex) valid(files []File) bool {
+	if x.n != len(files) || x.c != cap(files) || x.data != unsafe.SliceData(files) {
+		return false
+	}
+	return len(files) == 0 || files[len(files)-1].Name == x.last
+}
+
+// mark records the current shape of files in x.
+func (x *nameIndex) mark(files []File) {
+	x.data, x.n, x.c, x.last = unsafe.SliceData(files), len(files), cap(files), ""
+	if len(files) > 0 {
+		x.last = files[len(files)-1].Name
+	}
+}
+
+// nameIndex returns the index of a's files, building it if needed.
+func (a *Archive) nameIndex() *nameIndex {
+	key := weak.Make(a)
+	v, ok := indexes.Load(key)
+	if ok && v.(*nameIndex).valid(a.Files) {
+		return v.(*nameIndex)
+	}
+	if !ok {
+		runtime.AddCleanup(a, func(key weak.Pointer[Archive]) { indexes.Delete(key) }, key)
+	}
+	x := &nameIndex{names: make(map[string]int, len(a.Files))}
+	for i, f := range a.Files {
+		if _, ok := x.names[f.Name]; ok {
+			x.dups = true
+			continue
+		}
+		x.names[f.Name] = i
+	}
+	x.mark(a.Files)
+	indexes.Store(key, x)
+	return x
+}
+
+// lookup returns the index of a's files and the index in a.Files
+// of the first file with the given name, or -1 if there is none.
+func (a *Archive) lookup(name string) (*nameIndex, int) {
+	x := a.nameIndex()
+	i, ok := x.names[name]
+	if ok && a.Files[i].Name != name {
+		// Files was changed in place; start over.
+		a.Reindex()
+		x = a.nameIndex()
+		i, ok = x.names[name]
+	}
+	if !ok {
+		return x, -1
+	}
+	return x, i
+}
+
+// Reindex discards the index of file names used by Get, Has, Index,
+// Set and Delete, so that it is rebuilt on next use. It must be called
+// after giving a file a new name by assigning to Files[i].Name.
+func (a *Archive) Reindex() {
+	if _, ok := indexes.Load(weak.Make(a)); ok {
+		indexes.Store(weak.Make(a), &nameIndex{n: -1})
+	}
+}
+
+// Index returns the index in a.Files of the first file with the given name,
+// or -1 if there is none.
+func (a *Archive) Index(name string) int {
+	_, i := a.lookup(name)
+	return i
+}
+
+// Get returns the first file with the given name.
+// The boolean result reports whether such a file exists.
+func (a *Archive) Get(name string) (File, bool) {
+	if i := a.Index(name); i >= 0 {
+		return a.Files[i], true
+	}
+	return File{}, false
+}
+
+// Has reports whether the archive has a file with the given name.
+func (a *Archive) Has(name string) bool {
+	return a.Index(name) >= 0
+}
+
+// appendFile appends f, which must not already be in a, keeping x up to date.
+func (a *Archive) appendFile(x *nameIndex, f File) {
+	a.Files = append(a.Files, f)
+	x.names[f.Name] = len(a.Files) - 1
+	x.mark(a.Files)
+}
+
+// deleteFile removes the file at index i, whose name appears only once,
+// from a.Files, moving the later files down and keeping x up to date.
+func (a *Archive) deleteFile(x *nameIndex, i int) {
+	delete(x.names, a.Files[i].Name)
+	a.Files = slices.Delete(a.Files, i, i+1)
+	for j, f := range a.Files[i:] {
+		x.names[f.Name] = i + j
+	}
+	x.mark(a.Files)
+}
@@ -0,0 +1,154 @@
+package txtar
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	a := Parse([]byte("-- a --\n1\n-- b --\n2\n-- a --\n3\n"))
+	if f, ok := a.Get("a"); !ok || string(f.Data) != "1\n" {
+		t.Errorf("Get(a) = %q, %v; want first a", f.Data, ok)
+	}
+	if i := a.Index("b"); i != 1 {
+		t.Errorf("Index(b) = %d, want 1", i)
+	}
+	if a.Has("c") {
+		t.Errorf("Has(c) = true")
+	}
+
+	a.Set("c", []byte("4\n"))
+	if i := a.Index("c"); i != 3 {
+		t.Errorf("Index(c) = %d, want 3", i)
+	}
+	a.Delete("a")
+	if a.Has("a") || len(a.Files) != 2 {
+		t.Errorf("after Delete(a): files %v", names(a))
+	}
+	if i := a.Index("c"); i != 1 {
+		t.Errorf("Index(c) = %d, want 1", i)
+	}
+	a.Delete("c")
+	a.Delete("missing")
+	if got := names(a); fmt.Sprint(got) != "[b]" {
+		t.Errorf("files = %v, want [b]", got)
+	}
+
+	a.Set("d", nil)
+	a.Set("e", nil)
+	a.Delete("b")
+	for i, name := range []string{"d", "e"} {
+		if got := a.Index(name); got != i {
+			t.Errorf("after Delete(b): Index(%s) = %d, want %d", name, got, i)
+		}
+	}
+}
+
+func TestIndexDirectChanges(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(a *Archive)
+		want   []lookup // checked in order
+	}{
+		{"rename in place", func(a *Archive) { a.Files[0].Name = "x" }, []lookup{{"a", -1}, {"x", 0}, {"c", 2}}},
+		{"rename last", func(a *Archive) { a.Files[2].Name = "x" }, []lookup{{"x", 2}, {"c", -1}}},
+		{"append", func(a *Archive) { a.Files = append(a.Files, File{Name: "d"}) }, []lookup{{"d", 3}}},
+		{"truncate", func(a *Archive) { a.Files = a.Files[:1] }, []lookup{{"a", 0}, {"b", -1}}},
+		{"replace", func(a *Archive) { a.Files = []File{{Name: "c"}} }, []lookup{{"c", 0}, {"a", -1}}},
+		{"reslice", func(a *Archive) { a.Files = a.Files[1:] }, []lookup{{"b", 0}, {"a", -1}}},
+		{"swap", func(a *Archive) { a.Files[0], a.Files[1] = a.Files[1], a.Files[0] }, []lookup{{"a", 1}, {"b", 0}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := Parse([]byte("-- a --\n-- b --\n-- c --\n"))
+			a.Index("a") // build the index
+			tc.change(a)
+			for _, l := range tc.want {
+				if got := a.Index(l.name); got != l.index {
+					t.Errorf("Index(%q) = %d, want %d", l.name, got, l.index)
+				}
+			}
+		})
+	}
+}
+
+func TestReindex(t *testing.T) {
+	a := Parse([]byte("-- a --\n-- b --\n-- c --\n"))
+	a.Index("a") // build the index
+	a.Files[1].Name = "x"
+	a.Reindex()
+	if i := a.Index("x"); i != 1 {
+		t.Errorf("Index(x) = %d, want 1", i)
+	}
+	a.Set("x", []byte("new\n"))
+	if got := names(a); fmt.Sprint(got) != "[a x c]" {
+		t.Errorf("files = %v, want [a x c]", got)
+	}
+}
+
+func TestLookupKeepsArchivesEqual(t *testing.T) {
+	text := []byte("-- a --\n1\n-- b --\n2\n")
+	a, b := Parse(text), Parse(text)
+	a.Get("b")
+	a.Set("a", []byte("1\n"))
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("archives differ after lookups:\n%s\n%s", shortArchive(a), shortArchive(b))
+	}
+}
+
+type lookup struct {
+	name  string
+	index int
+}
+
+func names(a *Archive) []string {
+	var s []string
+	for _, f := range a.Files {
+		s = append(s, f.Name)
+	}
+	return s
+}
+
+// BenchmarkSetScaling builds archives of increasing size with Set.
+// The time per file should stay roughly constant as the number of
+// files grows.
+func BenchmarkSetScaling(b *testing.B) {
+	for _, n := range []int{1000, 10000, 100000} {
+		b.Run(fmt.Sprintf("files=%d", n), func(b *testing.B) {
+			for range b.N {
+				a := new(Archive)
+				for i := range n {
+					a.Set(fmt.Sprintf("dir/file%d.txt", i), nil)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/file")
+		})
+	}
+}
+
+func BenchmarkDeleteLast(b *testing.B) {
+	const n = 100000
+	for range b.N {
+		b.StopTimer()
+		a := new(Archive)
+		for i := range n {
+			a.Set(fmt.Sprintf("dir/file%d.txt", i), nil)
+		}
+		b.StartTimer()
+		for i := n - 1; i >= 0; i-- {
+			a.Delete(a.Files[i].Name)
+		}
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/file")
+}
+
+func BenchmarkGet(b *testing.B) {
+	a := new(Archive)
+	for i := range 10000 {
+		a.Set(fmt.Sprintf("file%d", i), nil)
+	}
+	b.ResetTimer()
+	for i := range b.N {
+		a.Get(fmt.Sprintf("file%d", i%10000))
+	}
+}
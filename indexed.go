@@ -0,0 +1,308 @@
+package txtar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+// An Index records where the data of each file in a serialized archive
+// is stored, so that files can be read without scanning the archive.
+type Index struct {
+	Size    int64 // size of the archive in bytes
+	Entries []IndexEntry
+}
+
+// An IndexEntry describes one file in an Index.
+// Only entries made by BuildIndex or ReadIndexFile record the
+// file's marker line; File and Open fail for others.
+type IndexEntry struct {
+	Name string
+	Position
+	Length int64 // length of the data as stored in the archive
+	Size   int64 // length of the data as Parse returns it
+
+	h     *header
+	fixNL bool // the stored data lacks the final newline Parse adds
+}
+
+// BuildIndex scans the archive r and returns its Index.
+func BuildIndex(r io.ReaderAt) (*Index, error) {
+	tr := NewReader(io.NewSectionReader(r, 0, math.MaxInt64))
+	x := new(Index)
+	for {
+		f, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var c dataCounter
+		if _, err := io.Copy(&c, tr); err != nil {
+			return nil, err
+		}
+		e := IndexEntry{Name: f.Name, Position: tr.Pos(), Size: c.n, h: tr.cur}
+		if tr.cur.text() && c.n > 0 && c.last != '\n' {
+			e.Size++
+			e.fixNL = true
+		}
+		x.Entries = append(x.Entries, e)
+	}
+	x.Size = tr.off
+	for i := range x.Entries {
+		end := x.Size
+		if i+1 < len(x.Entries) {
+			end = x.Entries[i+1].Offset
+		}
+		x.Entries[i].Length = end - x.Entries[i].DataOffset
+	}
+	return x, nil
+}
+
+// dataCounter is an io.Writer that counts the bytes written to it.
+type dataCounter struct {
+	n    int64
+	last byte // last byte written
+}
+
+func (c *dataCounter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		c.n += int64(len(p))
+		c.last = p[len(p)-1]
+	}
+	return len(p), nil
+}
+
+// errNoMarker is the error for an IndexEntry that records no marker line.
+var errNoMarker = errors.New("index entry has no marker line")
+
+// header returns the header of e's marker line.
+func (e *IndexEntry) header(op string) (*header, error) {
+	if e.h == nil {
+		return nil, &fs.PathError{Op: op, Path: e.Name, Err: errNoMarker}
+	}
+	return e.h, nil
+}
+
+// File returns the File described by e, with nil Data.
+func (e *IndexEntry) File() (File, error) {
+	h, err := e.header("stat")
+	if err != nil {
+		return File{}, err
+	}
+	return h.file(), nil
+}
+
+// Open returns a reader of the data of the file described by e,
+// read from the archive r and decoded as Reader does. Like Reader,
+// and unlike Parse, it does not add a final newline that a text
+// file lacks, though Size counts it.
+func (e *IndexEntry) Open(r io.ReaderAt) (io.Reader, error) {
+	h, err := e.header("open")
+	if err != nil {
+		return nil, err
+	}
+	if h.link != "" {
+		return strings.NewReader(h.link), nil
+	}
+	return h.enc.reader(io.NewSectionReader(r, e.DataOffset, e.Length)), nil
+}
+
+// OpenIndexed indexes the archive r and returns a file system of its files
+// that reads the data of a file from r only when the file is opened.
+func OpenIndexed(r io.ReaderAt) (fs.FS, error) {
+	x, err := BuildIndex(r)
+	if err != nil {
+		return nil, err
+	}
+	return x.FS(r)
+}
+
+// FS returns a file system of the files in x that reads their data
+// from the archive r. Like FS, it returns an error if any of the
+// file names are not valid file system names.
+func (x *Index) FS(r io.ReaderAt) (fs.FS, error) {
+	root := &node{fileinfo: fileinfo{path: ".", mode: readOnlyDir}}
+	fsys := &indexedFS{r: r, x: x, tree: FileSystem{nodes: map[string]*node{root.path: root}}}
+	for idx, e := range x.Entries {
+		if !fs.ValidPath(e.Name) {
+			return nil, fmt.Errorf("cannot create fs.FS from txtar.Index: file %q is an invalid path", e.Name)
+		}
+		f, err := e.File()
+		if err != nil {
+			return nil, fmt.Errorf("cannot create fs.FS from txtar.Index: %w", err)
+		}
+		mode := readOnly
+		if f.Mode != 0 {
+			mode = f.Mode.Perm()
+		}
+		n := &node{idx: idx, fileinfo: fileinfo{path: e.Name, size: int(e.Size), mode: mode, modTime: f.ModTime}}
+		if e.h.link != "" {
+			n.mode = fs.ModeSymlink | readOnly
+			n.link = e.h.link
+		}
+		if err := insert(&fsys.tree, n); err != nil {
+			return nil, fmt.Errorf("cannot create fs.FS from txtar.Index: %s", err)
+		}
+	}
+	return fsys, nil
+}
+
+// An indexedFS is the file system returned by Index.FS.
+// Like FileSystem, it implements fs.ReadLinkFS.
+type indexedFS struct {
+	r    io.ReaderAt
+	x    *Index
+	tree FileSystem // nodes of the files in x; tree.ar is nil
+}
+
+func (fsys *indexedFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	n, err := resolve(&fsys.tree, "open", ".", name, true)
+	if err != nil {
+		return nil, err
+	}
+	info := n.fileinfo
+	info.path = name // the name opened, not a link's target
+	if n.IsDir() {
+		return &openDir{fileinfo: info, entries: sortedEntries(n)}, nil
+	}
+	e := &fsys.x.Entries[n.idx]
+	r, err := e.Open(fsys.r)
+	if err != nil {
+		return nil, err
+	}
+	if e.fixNL {
+		r = io.MultiReader(r, strings.NewReader("\n"))
+	}
+	return &indexedFile{fileinfo: info, r: r}, nil
+}
+
+// ReadLink returns the target of the named symbolic link.
+func (fsys *indexedFS) ReadLink(name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	n, err := resolve(&fsys.tree, "readlink", ".", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.link == "" {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	return n.link, nil
+}
+
+// Lstat returns a FileInfo describing the named file.
+// If the file is a symbolic link, it describes the link itself.
+func (fsys *indexedFS) Lstat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "lstat", Path: name, Err: fs.ErrInvalid}
+	}
+	n, err := resolve(&fsys.tree, "lstat", ".", name, false)
+	if err != nil {
+		return nil, err
+	}
+	info := n.fileinfo
+	return &info, nil
+}
+
+// An indexedFile is a file of an indexedFS open for reading.
+type indexedFile struct {
+	fileinfo
+	r io.Reader
+}
+
+func (f *indexedFile) Stat() (fs.FileInfo, error) { return &f.fileinfo, nil }
+func (f *indexedFile) Close() error               { return nil }
+func (f *indexedFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+
+// ErrStaleIndex indicates that a sidecar index file does not match
+// the size and modification time of its archive.
+var ErrStaleIndex = errors.New("txtar: index does not match archive")
+
+// IndexFileName returns the name of the sidecar index file
+// of the named archive: the archive name followed by ".idx".
+func IndexFileName(archive string) string {
+	return archive + ".idx"
+}
+
+// indexFile is the JSON form of a sidecar index file.
+type indexFile struct {
+	Size    int64            `json:"size"`
+	ModTime time.Time        `json:"mtime"`
+	Entries []indexFileEntry `json:"entries"`
+}
+
+type indexFileEntry struct {
+	Marker string `json:"marker"`
+	Position
+	Length int64 `json:"length"`
+	Size   int64 `json:"size"`
+	FixNL  bool  `json:"fixnl,omitempty"`
+}
+
+// ReadIndexFile reads the sidecar index file of the named archive.
+// It returns ErrStaleIndex if the archive has changed since the
+// index file was written.
+func ReadIndexFile(archive string) (*Index, error) {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(IndexFileName(archive))
+	if err != nil {
+		return nil, err
+	}
+	var xf indexFile
+	if err := json.Unmarshal(data, &xf); err != nil {
+		return nil, fmt.Errorf("txtar: %s: %w", IndexFileName(archive), err)
+	}
+	if xf.Size != info.Size() || !xf.ModTime.Equal(info.ModTime()) {
+		return nil, ErrStaleIndex
+	}
+	x := &Index{Size: xf.Size, Entries: make([]IndexEntry, len(xf.Entries))}
+	for i, fe := range xf.Entries {
+		h, _ := isMarker([]byte(fe.Marker))
+		if h == nil {
+			return nil, fmt.Errorf("txtar: %s: invalid marker line %q", IndexFileName(archive), fe.Marker)
+		}
+		x.Entries[i] = IndexEntry{Name: h.name, Position: fe.Position, Length: fe.Length, Size: fe.Size, h: h, fixNL: fe.FixNL}
+	}
+	return x, nil
+}
+
+// WriteIndexFile writes x as the sidecar index file of the named archive,
+// recording the archive's modification time. It returns ErrStaleIndex if
+// the archive is not the size x records.
+func WriteIndexFile(archive string, x *Index) error {
+	info, err := os.Stat(archive)
+	if err != nil {
+		return err
+	}
+	if info.Size() != x.Size {
+		return ErrStaleIndex
+	}
+	xf := indexFile{Size: x.Size, ModTime: info.ModTime(), Entries: make([]indexFileEntry, len(x.Entries))}
+	for i, e := range x.Entries {
+		h, err := e.header("write index")
+		if err != nil {
+			return err
+		}
+		xf.Entries[i] = indexFileEntry{Marker: h.line(), Position: e.Position, Length: e.Length, Size: e.Size, FixNL: e.fixNL}
+	}
+	data, err := json.Marshal(xf)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(IndexFileName(archive), data, 0o666)
+}
@@ -0,0 +1,161 @@
+package txtar_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"txtar"
+)
+
+var indexTexts = []string{
+	"",
+	"comment only\n",
+	"comment\n-- a.txt --\nA\n-- dir/b.txt --\nB\nB\n-- empty --\n-- last --\nno newline",
+	"-- q.txtar (quoted) --\n>-- inner --\n-- bin (base64) --\nAAEC\n-- link -> a.txt --\n-- a.txt mode=0755 mtime=2024-01-02T15:04:05Z --\nx\n",
+	"-- a --\r\nwindows\r\n-- b --\r\n",
+}
+
+func TestBuildIndex(t *testing.T) {
+	for i, text := range indexTexts {
+		want, pos := txtar.ParseWithPositions([]byte(text))
+		x, err := txtar.BuildIndex(strings.NewReader(text))
+		if err != nil {
+			t.Fatalf("text %d: %v", i, err)
+		}
+		if x.Size != int64(len(text)) {
+			t.Errorf("text %d: Size = %d, want %d", i, x.Size, len(text))
+		}
+		if len(x.Entries) != len(want.Files) {
+			t.Fatalf("text %d: %d entries, want %d", i, len(x.Entries), len(want.Files))
+		}
+		tr := txtar.NewReader(strings.NewReader(text))
+		for j, e := range x.Entries {
+			wf := want.Files[j]
+			if e.Position != pos[j] {
+				t.Errorf("text %d, %s: Position = %+v, want %+v", i, e.Name, e.Position, pos[j])
+			}
+			if _, err := tr.Next(); err != nil {
+				t.Fatal(err)
+			}
+			wantData, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := e.Open(strings.NewReader(text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != string(wantData) {
+				t.Errorf("text %d, %s: Open read %q, Reader read %q", i, e.Name, data, wantData)
+			}
+			f, err := e.File()
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Data = wf.Data
+			if !reflect.DeepEqual(f, wf) {
+				t.Errorf("text %d: entry %d = %+v, want %+v", i, j, f, wf)
+			}
+			if e.Size != int64(len(wf.Data)) {
+				t.Errorf("text %d, %s: Size = %d, want %d", i, e.Name, e.Size, len(wf.Data))
+			}
+		}
+	}
+}
+
+func TestOpenIndexed(t *testing.T) {
+	text := "-- one.txt --\none\n-- 2/two.txt --\ntwo\n-- 2/3/three.txt (base64) --\ndGhyZWU=\n-- 2/link -> two.txt --\n-- last --\nno newline"
+	fsys, err := txtar.OpenIndexed(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "one.txt", "2/two.txt", "2/3/three.txt", "2/link", "last"); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"2/3/three.txt": "three", "2/link": "two\n", "last": "no newline\n"} {
+		if data, err := fs.ReadFile(fsys, name); err != nil || string(data) != want {
+			t.Errorf("ReadFile(%q) = %q, %v, want %q", name, data, err, want)
+		}
+	}
+
+	if _, err := txtar.OpenIndexed(strings.NewReader("-- a --\n-- a --\n")); err == nil {
+		t.Errorf("OpenIndexed with duplicate names succeeded")
+	}
+}
+
+func TestIndexEntryWithoutMarker(t *testing.T) {
+	var x txtar.Index
+	if err := json.Unmarshal([]byte(`{"Size":8,"Entries":[{"Name":"a","Offset":0,"DataOffset":8,"Length":0}]}`), &x); err != nil {
+		t.Fatal(err)
+	}
+	e := &x.Entries[0]
+	if _, err := e.File(); err == nil {
+		t.Errorf("File succeeded")
+	}
+	if _, err := e.Open(strings.NewReader("-- a --\n")); err == nil {
+		t.Errorf("Open succeeded")
+	}
+	if _, err := x.FS(strings.NewReader("-- a --\n")); err == nil {
+		t.Errorf("FS succeeded")
+	}
+	archive := filepath.Join(t.TempDir(), "a.txtar")
+	if err := os.WriteFile(archive, []byte("-- a --\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := txtar.WriteIndexFile(archive, &x); err == nil {
+		t.Errorf("WriteIndexFile succeeded")
+	}
+}
+
+func TestIndexFile(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "a.txtar")
+	text := indexTexts[3]
+	if err := os.WriteFile(archive, []byte(text), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txtar.ReadIndexFile(archive); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("ReadIndexFile before writing: err = %v, want fs.ErrNotExist", err)
+	}
+
+	x, err := txtar.BuildIndex(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := txtar.WriteIndexFile(archive, x); err != nil {
+		t.Fatal(err)
+	}
+	got, err := txtar.ReadIndexFile(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, x) {
+		t.Errorf("ReadIndexFile = %+v, want %+v", got, x)
+	}
+
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(archive, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := txtar.ReadIndexFile(archive); err != txtar.ErrStaleIndex {
+		t.Errorf("ReadIndexFile after touching archive: err = %v, want ErrStaleIndex", err)
+	}
+	if err := os.WriteFile(archive, []byte(text+"more\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	if err := txtar.WriteIndexFile(archive, x); err != txtar.ErrStaleIndex {
+		t.Errorf("WriteIndexFile for changed archive: err = %v, want ErrStaleIndex", err)
+	}
+}
@@ -0,0 +1,253 @@
+package txtar
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// An edit is one step of a line edit script turning a into b.
+type edit struct {
+	op   byte // '=', '-' or '+'
+	a, b int  // line indexes in a and b before the step
+}
+
+// splitLines splits data into lines, each keeping its trailing newline.
+// A final line without a newline is returned as is.
+func splitLines(data []byte) []string {
+	var lines []string
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, string(data[:i]))
+		data = data[i:]
+	}
+	return lines
+}
+
+// diffLines returns a shortest edit script turning a into b, using the
+// linear space refinement of Myers' O(ND) algorithm: it finds the middle
+// snake of an optimal path and recurses on either side of it, so memory
+// stays O(N+M) however different a and b are. Within each run of changes,
+// deletions come before insertions.
+func diffLines(a, b []string) []edit {
+	ids := make(map[string]int)
+	intern := func(lines []string) []int {
+		s := make([]int, len(lines))
+		for i, line := range lines {
+			id, ok := ids[line]
+			if !ok {
+				id = len(ids)
+				ids[line] = id
+			}
+			s[i] = id
+		}
+		return s
+	}
+	d := &differ{a: intern(a), b: intern(b)}
+	size := len(a) + len(b) + 2
+	d.vf, d.vb = make([]int, 2*size+1), make([]int, 2*size+1)
+	d.compare(0, len(a), 0, len(b))
+	normalize(d.script)
+	return d.script
+}
+
+// A differ holds the state of diffLines: the lines of a and b as small
+// integers, the forward and backward furthest points for middleSnake,
+// and the script so far.
+type differ struct {
+	a, b   []int
+	vf, vb []int
+	script []edit
+}
+
+// compare appends an edit script turning a[x0:x1] into b[y0:y1].
+func (d *differ) compare(x0, x1, y0, y1 int) {
+	for x0 < x1 && y0 < y1 && d.a[x0] == d.b[y0] {
+		d.script = append(d.script, edit{'=', x0, y0})
+		x0, y0 = x0+1, y0+1
+	}
+	suffix := 0
+	for x0 < x1 && y0 < y1 && d.a[x1-1] == d.b[y1-1] {
+		x1, y1, suffix = x1-1, y1-1, suffix+1
+	}
+	switch {
+	case x0 == x1:
+		for y := y0; y < y1; y++ {
+			d.script = append(d.script, edit{'+', x0, y})
+		}
+	case y0 == y1:
+		for x := x0; x < x1; x++ {
+			d.script = append(d.script, edit{'-', x, y0})
+		}
+	default:
+		x, y := d.middleSnake(x0, x1, y0, y1)
+		d.compare(x0, x, y0, y)
+		d.compare(x, x1, y, y1)
+	}
+	for i := range suffix {
+		d.script = append(d.script, edit{'=', x1 + i, y1 + i})
+	}
+}
+
+// middleSnake returns a point on a shortest path from (x0, y0) to (x1, y1)
+// that splits it into two shorter ones. It runs Myers' search forward from
+// the start and backward from the end until the two meet, using the
+// vectors vf and vb, indexed by diagonal, for the furthest points reached.
+// The first lines and the last lines of the two ranges must differ.
+func (d *differ) middleSnake(x0, x1, y0, y1 int) (int, int) {
+	n, m := x1-x0, y1-y0
+	delta := n - m
+	odd := delta%2 != 0
+	// Diagonal k is x-y in the forward search. The backward search counts
+	// x and y back from the end, so its diagonal kb is delta-k.
+	off := n + m + 1
+	vf, vb := d.vf, d.vb
+	vf[off+1], vb[off+1] = 0, 0
+	for D := 0; D <= (n+m+1)/2; D++ {
+		for k := -D; k <= D; k += 2 {
+			var x int
+			if k == -D || (k != D && vf[off+k-1] < vf[off+k+1]) {
+				x = vf[off+k+1]
+			} else {
+				x = vf[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && d.a[x0+x] == d.b[y0+y] {
+				x, y = x+1, y+1
+			}
+			vf[off+k] = x
+			// On an odd delta the paths meet on a forward step: check
+			// whether the backward path of round D-1 has reached x.
+			if kb := delta - k; odd && kb >= -(D-1) && kb <= D-1 && x+vb[off+kb] >= n {
+				return x0 + x, y0 + y
+			}
+		}
+		for kb := -D; kb <= D; kb += 2 {
+			var x int // distance back from x1
+			if kb == -D || (kb != D && vb[off+kb-1] < vb[off+kb+1]) {
+				x = vb[off+kb+1]
+			} else {
+				x = vb[off+kb-1] + 1
+			}
+			y := x - kb
+			for x < n && y < m && d.a[x1-x-1] == d.b[y1-y-1] {
+				x, y = x+1, y+1
+			}
+			vb[off+kb] = x
+			if k := delta - kb; !odd && k >= -D && k <= D && vf[off+k]+x >= n {
+				return x1 - x, y1 - y
+			}
+		}
+	}
+	panic("txtar: diff paths did not meet") // not reached
+}
+
+// normalize reorders each run of changes in script so that its
+// deletions come before its insertions.
+func normalize(script []edit) {
+	for i := 0; i < len(script); {
+		if script[i].op == '=' {
+			i++
+			continue
+		}
+		x, y := script[i].a, script[i].b
+		j, dels := i, 0
+		for ; j < len(script) && script[j].op != '='; j++ {
+			if script[j].op == '-' {
+				dels++
+			}
+		}
+		for k := i; k < j; k++ {
+			if n := k - i; n < dels {
+				script[k] = edit{'-', x + n, y}
+			} else {
+				script[k] = edit{'+', x + dels, y + n - dels}
+			}
+		}
+		i = j
+	}
+}
+
+// unifiedContext is the number of unchanged lines shown around each change.
+const unifiedContext = 3
+
+// unifiedDiff returns a unified diff turning old into new,
+// or "" if they are the same.
+func unifiedDiff(oldName, newName string, old, new []byte) string {
+	if bytes.Equal(old, new) {
+		return ""
+	}
+	if isBinary(old) || isBinary(new) {
+		return fmt.Sprintf("Binary files %s and %s differ\n", oldName, newName)
+	}
+	a, b := splitLines(old), splitLines(new)
+	script := diffLines(a, b)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+	for i := 0; i < len(script); {
+		// Find the next change and the extent of its hunk.
+		for i < len(script) && script[i].op == '=' {
+			i++
+		}
+		if i == len(script) {
+			break
+		}
+		start := max(i-unifiedContext, 0)
+		end := i
+		for j := i; j < len(script); j++ {
+			if script[j].op != '=' {
+				end = j + 1
+			} else if j-end >= 2*unifiedContext {
+				break
+			}
+		}
+		end = min(end+unifiedContext, len(script))
+
+		hunk := script[start:end]
+		var na, nb int
+		for _, e := range hunk {
+			if e.op != '+' {
+				na++
+			}
+			if e.op != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunk[0].a, na), hunkRange(hunk[0].b, nb))
+		for _, e := range hunk {
+			line := ""
+			switch e.op {
+			case '=':
+				out.WriteByte(' ')
+				line = a[e.a]
+			case '-':
+				out.WriteByte('-')
+				line = a[e.a]
+			case '+':
+				out.WriteByte('+')
+				line = b[e.b]
+			}
+			out.WriteString(line)
+			if !strings.HasSuffix(line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// hunkRange formats the start line and length of one side of a hunk.
+func hunkRange(start, n int) string {
+	if n == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if n == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, n)
+}
@@ -0,0 +1,188 @@
+package txtar
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{
+			name: "same",
+			old:  "a\nb\n",
+			new:  "a\nb\n",
+			want: "",
+		},
+		{
+			name: "change in middle",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			new:  "1\n2\n3\n4\nfive\n6\n7\n8\n9\n",
+			want: "--- old\n+++ new\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+ten\n",
+		},
+		{
+			name: "from empty",
+			old:  "",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "to empty",
+			old:  "a\n",
+			new:  "",
+			want: "--- old\n+++ new\n@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "no final newline",
+			old:  "a\nb",
+			new:  "a\nb\n",
+			want: "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		{
+			name: "binary",
+			old:  "a\n",
+			new:  "\x00",
+			want: "Binary files old and new differ\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff("old", "new", []byte(tt.old), []byte(tt.new))
+			if got != tt.want {
+				t.Errorf("unifiedDiff:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffLinesMinimal(t *testing.T) {
+	a := strings.Split("a b c a b b a", " ")
+	b := strings.Split("c b a b a c", " ")
+	script := diffLines(a, b)
+	var edits, ai, bi int
+	for _, e := range script {
+		switch e.op {
+		case '=':
+			if a[e.a] != b[e.b] || e.a != ai || e.b != bi {
+				t.Fatalf("bad equal step %+v at a=%d b=%d", e, ai, bi)
+			}
+			ai, bi = ai+1, bi+1
+		case '-':
+			edits++
+			ai++
+		case '+':
+			edits++
+			bi++
+		}
+	}
+	if ai != len(a) || bi != len(b) {
+		t.Errorf("script covers a[:%d] and b[:%d]", ai, bi)
+	}
+	if edits != 5 {
+		t.Errorf("script has %d edits, want 5", edits)
+	}
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewPCG(3, 4))
+	randLines := func() []string {
+		lines := make([]string, r.IntN(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.IntN(4)))
+		}
+		return lines
+	}
+	for range 2000 {
+		a, b := randLines(), randLines()
+		script := diffLines(a, b)
+		var edits, ai, bi int
+		last := byte('=')
+		for _, e := range script {
+			if e.a != ai || e.b != bi {
+				t.Fatalf("diffLines(%q, %q): step %+v at a=%d b=%d", a, b, e, ai, bi)
+			}
+			switch e.op {
+			case '=':
+				if a[e.a] != b[e.b] {
+					t.Fatalf("diffLines(%q, %q): bad equal step %+v", a, b, e)
+				}
+				ai, bi = ai+1, bi+1
+			case '-':
+				if last == '+' {
+					t.Fatalf("diffLines(%q, %q): deletion after insertion at %+v", a, b, e)
+				}
+				edits++
+				ai++
+			case '+':
+				edits++
+				bi++
+			}
+			last = e.op
+		}
+		if ai != len(a) || bi != len(b) {
+			t.Fatalf("diffLines(%q, %q): script covers a[:%d] and b[:%d]", a, b, ai, bi)
+		}
+		if want := len(a) + len(b) - 2*lcs(a, b); edits != want {
+			t.Fatalf("diffLines(%q, %q): %d edits, want %d", a, b, edits, want)
+		}
+	}
+}
+
+// lcs returns the length of a longest common subsequence of a and b.
+func lcs(a, b []string) int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(cur[j], prev[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+func BenchmarkUnifiedDiffLarge(b *testing.B) {
+	var old, new strings.Builder
+	for i := range 100000 {
+		fmt.Fprintf(&old, "line %d\n", i)
+		if i%1000 == 0 {
+			fmt.Fprintf(&new, "changed %d\n", i)
+		} else {
+			fmt.Fprintf(&new, "line %d\n", i)
+		}
+	}
+	oldData, newData := []byte(old.String()), []byte(new.String())
+	b.ReportAllocs()
+	for range b.N {
+		unifiedDiff("a", "b", oldData, newData)
+	}
+}
+
+// BenchmarkUnifiedDiffDisjoint diffs two files with no lines in common,
+// the worst case for memory in a search that records its path.
+func BenchmarkUnifiedDiffDisjoint(b *testing.B) {
+	var old, new strings.Builder
+	for i := range 8000 {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+	oldData, newData := []byte(old.String()), []byte(new.String())
+	b.ReportAllocs()
+	for range b.N {
+		unifiedDiff("a", "b", oldData, newData)
+	}
+}
@@ -0,0 +1,30 @@
+package txtar
+
+import "io"
+
+// MapFile parses the named file as an archive without reading it into memory
+// first. On Linux the file is mapped read-only into memory and the Data of
+// the returned Archive's files are slices of the mapping, except where Parse
+// copies data: quoted and base64 encoded files, and a final file that lacks
+// its final newline. Elsewhere the file is read into memory.
+//
+// The mapped data must not be modified, and must not be used after
+// the returned io.Closer is closed. The Archive works with FS as it is.
+// Files that cannot be mapped, such as pipes and files in /proc, are
+// read into memory instead.
+//
+// The mapping is shared with the file, so changes to the file show through.
+// Reading data past the end of a file that is truncated while it is mapped
+// raises SIGBUS, which crashes the program.
+func MapFile(path string) (*Archive, io.Closer, error) {
+	data, c, err := mapFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return Parse(data), c, nil
+}
+
+// nopCloser is the io.Closer of a file that is not mapped.
+type nopCloser struct{}
+
+func (nopCloser) Close() error { return nil }
@@ -0,0 +1,57 @@
+package txtar
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+)
+
+// mapFile maps the named file read-only into memory.
+// Files that are not regular, that report a size of zero, such as
+// those in /proc, or that cannot be mapped are read instead.
+func mapFile(path string) ([]byte, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	size := info.Size()
+	switch {
+	case !info.Mode().IsRegular() || size == 0:
+		return readFile(f)
+	case int64(int(size)) != size:
+		return nil, nil, fmt.Errorf("txtar: %s: file too large to map", path)
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return readFile(f)
+	}
+	return data, &mapping{data: data}, nil
+}
+
+// readFile reads f into memory, for a file that is not mapped.
+func readFile(f *os.File) ([]byte, io.Closer, error) {
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, nopCloser{}, nil
+}
+
+// A mapping is the io.Closer of a mapped file. Closing it unmaps the file.
+type mapping struct {
+	once sync.Once
+	data []byte
+	err  error
+}
+
+func (m *mapping) Close() error {
+	m.once.Do(func() { m.err = syscall.Munmap(m.data) })
+	return m.err
+}
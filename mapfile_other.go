@@ -0,0 +1,17 @@
+//go:build !linux
+
+package txtar
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads the named file into memory.
+func mapFile(path string) ([]byte, io.Closer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, nopCloser{}, nil
+}
@@ -0,0 +1,90 @@
+package txtar_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"testing/fstest"
+
+	"txtar"
+)
+
+func TestMapFile(t *testing.T) {
+	dir := t.TempDir()
+	for i, text := range indexTexts {
+		name := filepath.Join(dir, "a.txtar")
+		if err := os.WriteFile(name, []byte(text), 0o666); err != nil {
+			t.Fatal(err)
+		}
+		a, c, err := txtar.MapFile(name)
+		if err != nil {
+			t.Fatalf("text %d: %v", i, err)
+		}
+		if want := txtar.Parse([]byte(text)); !reflect.DeepEqual(a.Files, want.Files) || string(a.Comment) != string(want.Comment) {
+			t.Errorf("text %d: MapFile = %+v, want %+v", i, a, want)
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("text %d: Close: %v", i, err)
+		}
+		if err := c.Close(); err != nil {
+			t.Errorf("text %d: second Close: %v", i, err)
+		}
+	}
+
+	if _, _, err := txtar.MapFile(filepath.Join(dir, "missing.txtar")); !os.IsNotExist(err) {
+		t.Errorf("MapFile of missing file: err = %v, want not exist", err)
+	}
+}
+
+func TestMapFileFS(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.txtar")
+	if err := os.WriteFile(name, []byte("-- one.txt --\none\n-- 2/two.txt --\ntwo\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	a, c, err := txtar.MapFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	fsys, err := txtar.FS(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, "one.txt", "2/two.txt"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMapFileAppend(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "a.txtar")
+	if err := os.WriteFile(name, []byte("comment\n-- a --\na\n-- b --\nb\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	a, c, err := txtar.MapFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// Appending must copy rather than write into the read-only mapping.
+	a.Comment = append(a.Comment, "more\n"...)
+	a.Files[0].Data = append(a.Files[0].Data, "more\n"...)
+	if got, want := string(txtar.Format(a)), "comment\nmore\n-- a --\na\nmore\n-- b --\nb\n"; got != want {
+		t.Errorf("Format after append = %q, want %q", got, want)
+	}
+}
+
+func TestMapFileUnmappable(t *testing.T) {
+	const name = "/proc/self/status" // reports a size of zero
+	if _, err := os.Stat(name); err != nil {
+		t.Skip(err)
+	}
+	a, c, err := txtar.MapFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if len(a.Comment) == 0 {
+		t.Errorf("MapFile(%s) returned an empty archive", name)
+	}
+}
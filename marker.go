@@ -0,0 +1,203 @@
+package txtar
+
+import (
+	"fmt"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A header is the parsed form of a file marker line.
+type header struct {
+	name    string
+	link    string // symbolic link target, or "" for regular files
+	enc     encoding
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// headerFor returns the header Format writes for f.
+func headerFor(f File) *header {
+	if f.Mode&fs.ModeSymlink != 0 {
+		return &header{name: f.Name, link: string(f.Data), modTime: f.ModTime}
+	}
+	return &header{
+		name:    f.Name,
+		enc:     encodingFor(f.Data),
+		mode:    f.Mode.Perm(), // only permission bits are recorded
+		modTime: f.ModTime,
+	}
+}
+
+// file returns the File described by h, with nil Data.
+func (h *header) file() File {
+	if h.link != "" {
+		return File{Name: h.name, Mode: fs.ModeSymlink, ModTime: h.modTime}
+	}
+	return File{Name: h.name, Mode: h.mode, ModTime: h.modTime}
+}
+
+// encode returns data as it is stored for the file described by h.
+func (h *header) encode(data []byte) []byte {
+	if h.link != "" {
+		return nil
+	}
+	return h.enc.encode(data)
+}
+
+// data returns the data of the file described by h, stored as raw.
+func (h *header) data(raw []byte) []byte {
+	if h.link != "" {
+		return []byte(h.link)
+	}
+	return h.enc.decode(raw)
+}
+
+// text reports whether the data of the file described by h is text,
+// to which the rule about a missing final newline applies.
+func (h *header) text() bool {
+	return h.link == "" && h.enc != base64Encoded
+}
+
+// parseHeader parses the text between the "-- " and " --" of a file marker line.
+// It returns nil if the text does not name a file.
+func parseHeader(s string) *header {
+	s = strings.TrimSpace(s)
+	h := new(header)
+	for {
+		i := strings.LastIndexByte(s, ' ')
+		if i < 0 || strings.TrimSpace(s[:i]) == "" || !h.parseAttr(s[i+1:]) {
+			break
+		}
+		s = strings.TrimSpace(s[:i])
+	}
+	name, link, ok := splitLink(s)
+	if ok {
+		h.link = unquoteOr(link)
+	} else {
+		name = s
+	}
+	if strings.HasPrefix(name, `"`) {
+		if name, err := strconv.Unquote(name); err == nil {
+			h.name = name
+			return h
+		}
+	}
+	if name == "" {
+		return nil
+	}
+	h.name = name
+	return h
+}
+
+// splitLink splits s of the form "NAME -> TARGET", where NAME may be a
+// quoted string containing " -> ". It reports false if s is not of that form.
+func splitLink(s string) (name, link string, ok bool) {
+	const arrow = " -> "
+	if q, err := strconv.QuotedPrefix(s); err == nil {
+		name, link, ok = q, "", strings.HasPrefix(s[len(q):], arrow)
+		if ok {
+			link = s[len(q)+len(arrow):]
+		}
+	} else {
+		name, link, ok = strings.Cut(s, arrow)
+	}
+	link = strings.TrimSpace(link)
+	if !ok || strings.TrimSpace(name) == "" || link == "" || link == `""` {
+		return "", "", false
+	}
+	return strings.TrimSpace(name), link, true
+}
+
+// unquoteOr returns s unquoted if it is a valid Go string literal,
+// and s itself otherwise.
+func unquoteOr(s string) string {
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+// parseAttr records the flag or attribute attr from a file marker line in h.
+// It reports false if attr is not a valid flag or attribute,
+// or if h already has it.
+func (h *header) parseAttr(attr string) bool {
+	switch attr {
+	case quotedFlag, base64Flag:
+		if h.enc != plain {
+			return false
+		}
+		h.enc = quoted
+		if attr == base64Flag {
+			h.enc = base64Encoded
+		}
+		return true
+	}
+
+	key, val, _ := strings.Cut(attr, "=")
+	switch key {
+	case "mode":
+		m, err := strconv.ParseUint(val, 8, 32)
+		if err != nil || h.mode != 0 || m == 0 || fs.FileMode(m)&^fs.ModePerm != 0 {
+			return false
+		}
+		h.mode = fs.FileMode(m)
+	case "mtime":
+		t, err := time.Parse(time.RFC3339Nano, val)
+		if err != nil || !h.modTime.IsZero() || t.IsZero() {
+			return false
+		}
+		h.modTime = t
+	default:
+		return false
+	}
+	return true
+}
+
+// line returns the file marker line for h, including the final newline.
+func (h *header) line() string {
+	var b strings.Builder
+	b.WriteString("-- ")
+	b.WriteString(formatName(h.name))
+	if h.link != "" {
+		b.WriteString(" -> ")
+		b.WriteString(formatLink(h.link))
+	}
+	if h.enc != plain {
+		b.WriteString(" " + h.enc.flag())
+	}
+	if h.mode.Perm() != 0 {
+		fmt.Fprintf(&b, " mode=%04o", h.mode.Perm())
+	}
+	if !h.modTime.IsZero() {
+		b.WriteString(" mtime=" + h.modTime.UTC().Format(time.RFC3339Nano))
+	}
+	b.WriteString(" --\n")
+	return b.String()
+}
+
+// formatName returns name as it is written in a file marker line:
+// unchanged if parseHeader reads it back as is, and quoted otherwise.
+func formatName(name string) string {
+	if !strings.ContainsAny(name, "\r\n") {
+		if h := parseHeader(name); h != nil && *h == (header{name: name}) {
+			return name
+		}
+	}
+	return strconv.Quote(name)
+}
+
+// formatLink returns the symbolic link target link as it is written
+// in a file marker line: unchanged if parseHeader reads it back as is,
+// and quoted otherwise.
+func formatLink(link string) string {
+	if !strings.ContainsAny(link, "\r\n") {
+		if h := parseHeader("x -> " + link); h != nil && *h == (header{name: "x", link: link}) {
+			return link
+		}
+	}
+	return strconv.Quote(link)
+}
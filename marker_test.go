@@ -0,0 +1,211 @@
+package txtar
+
+import (
+	"bytes"
+	"io/fs"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNameRoundTrip(t *testing.T) {
+	names := []string{
+		"plain.txt",
+		"file 2",
+		" leading",
+		"trailing ",
+		"a -- b",
+		"ends with --",
+		"line\nbreak",
+		"carriage\rreturn",
+		`"already quoted"`,
+		`"unterminated`,
+		`say "hi"`,
+		"x (quoted)",
+		"x (base64)",
+		"",
+		"\xff\xfe",
+	}
+	for _, name := range names {
+		a := &Archive{Comment: []byte{}, Files: []File{
+			{Name: name, Data: []byte("data\n")},
+			{Name: "next", Data: []byte("next\n")},
+		}}
+		text := Format(a)
+		if got := Parse(text); !reflect.DeepEqual(got, a) {
+			t.Errorf("name %q: Parse(Format) wrong output:\nhave:\n%s\nwant:\n%s", name, shortArchive(got), shortArchive(a))
+		}
+
+		r := NewReader(bytes.NewReader(text))
+		f, err := r.Next()
+		if err != nil {
+			t.Fatalf("name %q: Next: %v", name, err)
+		}
+		if f.Name != name {
+			t.Errorf("name %q: Reader returned %q", name, f.Name)
+		}
+	}
+}
+
+func TestFormatName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"plain.txt", "plain.txt"},
+		{"file 2", "file 2"},
+		{"a -- b", "a -- b"},
+		{" leading", `" leading"`},
+		{"line\nbreak", `"line\nbreak"`},
+		{`"x"`, `"\"x\""`},
+		{"x (quoted)", `"x (quoted)"`},
+		{"", `""`},
+	}
+	for _, tt := range tests {
+		if got := formatName(tt.name); got != tt.want {
+			t.Errorf("formatName(%q) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestAttributes(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name string
+		text string
+		file File
+		from File // if set, the file Format writes as text instead of file
+	}{
+		{
+			name: "mode and mtime",
+			text: "-- run.sh mode=0755 mtime=2024-01-02T15:04:05Z --\necho hi\n",
+			file: File{Name: "run.sh", Data: []byte("echo hi\n"), Mode: 0o755, ModTime: mtime},
+		},
+		{
+			name: "mode only",
+			text: "-- run.sh mode=0700 --\necho hi\n",
+			file: File{Name: "run.sh", Data: []byte("echo hi\n"), Mode: 0o700},
+		},
+		{
+			name: "with flag",
+			text: "-- bin (base64) mode=0755 --\nAAE=\n",
+			file: File{Name: "bin", Data: []byte{0, 1}, Mode: 0o755},
+		},
+		{
+			name: "quoted name",
+			text: "-- \"a mode=0755\" mtime=2024-01-02T15:04:05Z --\nx\n",
+			file: File{Name: "a mode=0755", Data: []byte("x\n"), ModTime: mtime},
+		},
+		{
+			name: "no permission bits",
+			text: "-- a --\nx\n",
+			file: File{Name: "a", Data: []byte("x\n")},
+			from: File{Name: "a", Data: []byte("x\n"), Mode: fs.ModeDir},
+		},
+		{
+			name: "other mode bits",
+			text: "-- a mode=0644 --\nx\n",
+			file: File{Name: "a", Data: []byte("x\n"), Mode: 0o644},
+			from: File{Name: "a", Data: []byte("x\n"), Mode: fs.ModeSetuid | 0o644},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := &Archive{Comment: []byte{}, Files: []File{tt.file}}
+			a := Parse([]byte(tt.text))
+			if !reflect.DeepEqual(a, want) {
+				t.Fatalf("Parse: wrong output:\nhave: %+v\nwant: %+v", a.Files, want.Files)
+			}
+			if got := string(Format(a)); got != tt.text {
+				t.Errorf("Format = %q, want %q", got, tt.text)
+			}
+			if tt.from.Name != "" {
+				if got := string(Format(&Archive{Files: []File{tt.from}})); got != tt.text {
+					t.Errorf("Format(%v) = %q, want %q", tt.from.Mode, got, tt.text)
+				}
+			}
+
+			r := NewReader(strings.NewReader(tt.text))
+			f, err := r.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.Data = tt.file.Data
+			if !reflect.DeepEqual(f, tt.file) {
+				t.Errorf("Reader: got %+v, want %+v", f, tt.file)
+			}
+		})
+	}
+}
+
+func TestModTimeUTC(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 16, 4, 5, 500, time.FixedZone("CET", 3600))
+	a := &Archive{Files: []File{{Name: "a", Data: []byte("x\n"), ModTime: mtime}}}
+	text := Format(a)
+	if want := "-- a mtime=2024-01-02T15:04:05.0000005Z --\n"; !strings.HasPrefix(string(text), want) {
+		t.Fatalf("Format = %q, want prefix %q", text, want)
+	}
+	got := Parse(text).Files[0].ModTime
+	if !got.Equal(mtime) {
+		t.Errorf("ModTime = %v, want %v", got, mtime)
+	}
+	if got.Location() != time.UTC {
+		t.Errorf("ModTime location = %v, want UTC", got.Location())
+	}
+}
+
+func TestInvalidAttributes(t *testing.T) {
+	names := map[string]string{
+		"-- a mode=9 --\n":              "a mode=9",
+		"-- a mode=01777 --\n":          "a mode=01777",
+		"-- a mtime=yesterday --\n":     "a mtime=yesterday",
+		"-- mode=0755 --\n":             "mode=0755",
+		"-- a mode=0755 mode=0700 --\n": "a mode=0755",
+	}
+	for text, want := range names {
+		a := Parse([]byte(text))
+		if len(a.Files) != 1 || a.Files[0].Name != want {
+			t.Errorf("Parse(%q) files = %+v, want name %q", text, a.Files, want)
+		}
+	}
+}
+
+func TestSymlinkEntries(t *testing.T) {
+	mtime := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		text string
+		file File
+	}{
+		{"-- link -> target --\n", File{Name: "link", Data: []byte("target"), Mode: fs.ModeSymlink}},
+		{"-- a/b -> ../c d --\n", File{Name: "a/b", Data: []byte("../c d"), Mode: fs.ModeSymlink}},
+		{"-- link -> target mtime=2024-01-02T15:04:05Z --\n", File{Name: "link", Data: []byte("target"), Mode: fs.ModeSymlink, ModTime: mtime}},
+		{"-- \"a -> b\" -> c --\n", File{Name: "a -> b", Data: []byte("c"), Mode: fs.ModeSymlink}},
+		{"-- a -> b -> c --\n", File{Name: "a", Data: []byte("b -> c"), Mode: fs.ModeSymlink}},
+		{"-- a -> \" b \" --\n", File{Name: "a", Data: []byte(" b "), Mode: fs.ModeSymlink}},
+		{"-- a -> \"b (quoted)\" --\n", File{Name: "a", Data: []byte("b (quoted)"), Mode: fs.ModeSymlink}},
+	}
+	for _, tt := range tests {
+		want := &Archive{Comment: []byte{}, Files: []File{tt.file, {Name: "next", Data: []byte("x\n")}}}
+		text := tt.text + "-- next --\nx\n"
+		a := Parse([]byte(text))
+		if !reflect.DeepEqual(a, want) {
+			t.Errorf("Parse(%q):\nhave: %+v\nwant: %+v", text, a.Files, want.Files)
+			continue
+		}
+		if got := string(Format(a)); got != text {
+			t.Errorf("Format = %q, want %q", got, text)
+		}
+
+		r := NewReader(strings.NewReader(text))
+		var files []File
+		for f, err := range r.AllWithData() {
+			if err != nil {
+				t.Fatal(err)
+			}
+			files = append(files, f)
+		}
+		if !reflect.DeepEqual(files, want.Files) {
+			t.Errorf("Reader(%q):\nhave: %+v\nwant: %+v", text, files, want.Files)
+		}
+	}
+}
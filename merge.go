@@ -0,0 +1,154 @@
+package txtar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A MergePolicy says how Merge resolves a file that is in both archives
+// with different contents.
+type MergePolicy int
+
+const (
+	KeepFirst        MergePolicy = iota // keep the file already in dst
+	KeepLast                            // replace the file in dst with the one from src
+	ErrorOnConflict                     // fail without changing dst
+	RenameWithSuffix                    // add the file from src under a new name
+)
+
+var mergePolicyNames = []string{
+	KeepFirst:        "keep-first",
+	KeepLast:         "keep-last",
+	ErrorOnConflict:  "error-on-conflict",
+	RenameWithSuffix: "rename-with-suffix",
+}
+
+func (p MergePolicy) String() string {
+	if p >= 0 && int(p) < len(mergePolicyNames) {
+		return mergePolicyNames[p]
+	}
+	return "MergePolicy(" + strconv.Itoa(int(p)) + ")"
+}
+
+// ParseMergePolicy returns the MergePolicy with the given name:
+// "keep-first", "keep-last", "error-on-conflict" or "rename-with-suffix".
+func ParseMergePolicy(s string) (MergePolicy, error) {
+	for p, name := range mergePolicyNames {
+		if s == name {
+			return MergePolicy(p), nil
+		}
+	}
+	return 0, fmt.Errorf("txtar: unknown merge policy %q", s)
+}
+
+// ErrConflict is returned by Merge under ErrorOnConflict
+// when the archives disagree about a file.
+var ErrConflict = errors.New("conflicting files")
+
+// A Conflict describes a file that two archives disagree about.
+type Conflict struct {
+	Name    string // name of the file, or "" for the comment in Merge3
+	Renamed string // name the file was added under, for RenameWithSuffix
+}
+
+// Merge adds the comment and files of src to dst.
+//
+// The comment of src is appended to that of dst, unless they are the same.
+// Files of src that are not in dst are added at the end, and files that
+// are in dst with the same data and attributes are skipped. Any others
+// are conflicts, resolved according to policy. A file of src that differs
+// from an earlier file of src with the same name conflicts with the file
+// that earlier one added. Merge returns the conflicts it found, in the
+// order of src's files.
+//
+// Under ErrorOnConflict, Merge returns the conflicts and an error wrapping
+// ErrConflict, and dst is left unchanged.
+func Merge(dst, src *Archive, policy MergePolicy) (conflicts []Conflict, err error) {
+	if policy < KeepFirst || policy > RenameWithSuffix {
+		return nil, fmt.Errorf("txtar: merge: invalid policy %v", policy)
+	}
+	// files maps each name in dst to the file Get returns for it.
+	files := make(map[string]File, len(dst.Files))
+	for _, f := range slices.Backward(dst.Files) {
+		files[f.Name] = f
+	}
+	if policy == ErrorOnConflict {
+		merged := maps.Clone(files)
+		for _, f := range src.Files {
+			if g, ok := merged[f.Name]; ok {
+				if !sameFile(f, g) {
+					conflicts = append(conflicts, Conflict{Name: f.Name})
+				}
+				continue
+			}
+			merged[f.Name] = f
+		}
+		if len(conflicts) > 0 {
+			return conflicts, fmt.Errorf("txtar: merge: %d %w", len(conflicts), ErrConflict)
+		}
+	}
+
+	switch {
+	case len(src.Comment) == 0 || bytes.Equal(dst.Comment, src.Comment):
+	case len(dst.Comment) == 0:
+		dst.Comment = bytes.Clone(src.Comment)
+	default:
+		dst.Comment = append(FixNL(bytes.Clone(dst.Comment)), src.Comment...)
+	}
+
+	for _, f := range src.Files {
+		g, ok := files[f.Name]
+		if !ok {
+			dst.Files = append(dst.Files, f)
+			files[f.Name] = f
+			continue
+		}
+		if sameFile(f, g) {
+			continue
+		}
+		c := Conflict{Name: f.Name}
+		switch policy {
+		case KeepLast:
+			dst.SetFile(f)
+			files[f.Name] = f
+		case RenameWithSuffix:
+			f.Name = uniqueName(f.Name, dst, src)
+			c.Renamed = f.Name
+			dst.Files = append(dst.Files, f)
+			files[f.Name] = f
+		}
+		conflicts = append(conflicts, c)
+	}
+	return conflicts, nil
+}
+
+// sameFile reports whether f and g have the same name, data and attributes.
+func sameFile(f, g File) bool {
+	return f.Name == g.Name && bytes.Equal(f.Data, g.Data) && f.Mode == g.Mode && f.ModTime.Equal(g.ModTime)
+}
+
+// uniqueName returns name with the smallest numeric suffix, inserted before
+// the extension, that is not used in any of archives: a.txt becomes a-1.txt.
+func uniqueName(name string, archives ...*Archive) string {
+	ext := path.Ext(name)
+	if ext == name || strings.HasSuffix(name, "/"+ext) {
+		ext = "" // a dot file, not an extension
+	}
+	base := strings.TrimSuffix(name, ext)
+	for i := 1; ; i++ {
+		candidate := base + "-" + strconv.Itoa(i) + ext
+		used := false
+		for _, a := range archives {
+			used = used || a.Has(candidate)
+		}
+		if !used {
+			return candidate
+		}
+	}
+}
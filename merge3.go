@@ -0,0 +1,185 @@
+package txtar
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Labels of the conflict markers written by Merge3.
+const (
+	conflictOurs   = "<<<<<<< ours\n"
+	conflictSep    = "=======\n"
+	conflictTheirs = ">>>>>>> theirs\n"
+)
+
+// Merge3 merges the changes made to base in ours and in theirs, file by file,
+// and returns the result together with the files that could not be merged
+// cleanly. A Conflict with an empty Name stands for the comment.
+//
+// A file changed on only one side takes that side's version, which may mean
+// removing it. A file changed on both sides has its lines merged. Lines
+// changed differently on both sides are written with conflict markers, in
+// that file only:
+//
+//	<<<<<<< ours
+//	our lines
+//	=======
+//	their lines
+//	>>>>>>> theirs
+//
+// A file changed on one side and removed on the other, or binary data
+// changed on both sides, is also a conflict; the changed file, or our
+// version of the binary data, is kept.
+//
+// Files keep the order they have in ours; files added in theirs follow
+// in the order they have there.
+func Merge3(base, ours, theirs *Archive) (*Archive, []Conflict) {
+	var conflicts []Conflict
+	merged := new(Archive)
+
+	comment, ok := mergeData(base.Comment, ours.Comment, theirs.Comment)
+	if !ok {
+		conflicts = append(conflicts, Conflict{})
+	}
+	merged.Comment = comment
+
+	merge := func(name string) {
+		if merged.Has(name) {
+			return
+		}
+		b, inBase := base.Get(name)
+		o, inOurs := ours.Get(name)
+		t, inTheirs := theirs.Get(name)
+		sameAs := func(f File, in bool, g File, inG bool) bool {
+			return in == inG && (!in || sameFile(f, g))
+		}
+		switch {
+		case sameAs(o, inOurs, t, inTheirs), sameAs(t, inTheirs, b, inBase):
+			if inOurs {
+				merged.SetFile(o)
+			}
+		case sameAs(o, inOurs, b, inBase):
+			if inTheirs {
+				merged.SetFile(t)
+			}
+		case !inOurs:
+			merged.SetFile(t) // removed by us, changed by them
+			conflicts = append(conflicts, Conflict{Name: name})
+		case !inTheirs:
+			merged.SetFile(o) // changed by us, removed by them
+			conflicts = append(conflicts, Conflict{Name: name})
+		default:
+			f := o
+			f.Mode = mergeAttr(b.Mode, o.Mode, t.Mode)
+			if o.ModTime.Equal(b.ModTime) {
+				f.ModTime = t.ModTime
+			}
+			var ok bool
+			if f.Data, ok = mergeData(b.Data, o.Data, t.Data); !ok {
+				conflicts = append(conflicts, Conflict{Name: name})
+			}
+			merged.SetFile(f)
+		}
+	}
+	for _, f := range ours.Files {
+		merge(f.Name)
+	}
+	for _, f := range theirs.Files {
+		merge(f.Name)
+	}
+	return merged, conflicts
+}
+
+// mergeAttr returns the value of an attribute after a three-way merge,
+// preferring ours if both sides changed it.
+func mergeAttr[T comparable](base, ours, theirs T) T {
+	if ours == base {
+		return theirs
+	}
+	return ours
+}
+
+// mergeData merges the lines of ours and theirs, which are both changed
+// from base. It reports false if there were conflicts, which are marked
+// in the result. Binary data cannot be merged, and gives ours.
+func mergeData(base, ours, theirs []byte) ([]byte, bool) {
+	switch {
+	case bytes.Equal(ours, theirs), bytes.Equal(theirs, base):
+		return ours, true
+	case bytes.Equal(ours, base):
+		return theirs, true
+	case isBinary(base) || isBinary(ours) || isBinary(theirs):
+		return ours, false
+	}
+	lines, clean := merge3Lines(splitLines(base), splitLines(ours), splitLines(theirs))
+	var buf bytes.Buffer
+	for _, l := range lines {
+		buf.WriteString(l)
+	}
+	return buf.Bytes(), clean
+}
+
+// merge3Lines merges the changes from base to ours and from base to theirs,
+// in the manner of diff3. It reports false if there were conflicts.
+func merge3Lines(base, ours, theirs []string) (merged []string, clean bool) {
+	inOurs, inTheirs := matchLines(base, ours), matchLines(base, theirs)
+	clean = true
+	var i, j, k int // start of the current chunk in base, ours and theirs
+	for {
+		// Find the next base line kept by both sides.
+		p := i
+		for p < len(base) && (inOurs[p] < 0 || inTheirs[p] < 0) {
+			p++
+		}
+		oEnd, tEnd := len(ours), len(theirs)
+		if p < len(base) {
+			oEnd, tEnd = inOurs[p], inTheirs[p]
+		}
+
+		b, o, t := base[i:p], ours[j:oEnd], theirs[k:tEnd]
+		switch {
+		case slices.Equal(o, b):
+			merged = append(merged, t...)
+		case slices.Equal(t, b), slices.Equal(o, t):
+			merged = append(merged, o...)
+		default:
+			clean = false
+			merged = append(merged, conflictOurs)
+			merged = appendTerminated(merged, o)
+			merged = append(merged, conflictSep)
+			merged = appendTerminated(merged, t)
+			merged = append(merged, conflictTheirs)
+		}
+
+		if p == len(base) {
+			return merged, clean
+		}
+		merged = append(merged, base[p])
+		i, j, k = p+1, oEnd+1, tEnd+1
+	}
+}
+
+// matchLines returns, for each line of a, the index of the line of b it is
+// kept as in a shortest edit script from a to b, or -1 if it is removed.
+func matchLines(a, b []string) []int {
+	m := make([]int, len(a))
+	for i := range m {
+		m[i] = -1
+	}
+	for _, e := range diffLines(a, b) {
+		if e.op == '=' {
+			m[e.a] = e.b
+		}
+	}
+	return m
+}
+
+// appendTerminated appends lines to dst, adding a newline to the last
+// one if it has none so that a conflict marker can follow.
+func appendTerminated(dst, lines []string) []string {
+	dst = append(dst, lines...)
+	if n := len(dst); len(lines) > 0 && dst[n-1][len(dst[n-1])-1] != '\n' {
+		dst[n-1] += "\n"
+	}
+	return dst
+}
@@ -0,0 +1,138 @@
+package txtar
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := Parse([]byte(`comment
+-- a.txt --
+1
+2
+3
+4
+5
+-- same.txt --
+same
+-- ours-del.txt --
+x
+-- theirs-del.txt --
+y
+-- both-del.txt --
+z
+-- conflict.txt --
+one
+two
+three
+-- del-mod.txt --
+keep
+`))
+	ours := Parse([]byte(`comment
+-- a.txt --
+one
+2
+3
+4
+5
+-- same.txt --
+same
+-- theirs-del.txt --
+y
+-- conflict.txt --
+one
+TWO
+three
+-- del-mod.txt --
+changed
+-- ours-new.txt --
+o
+`))
+	theirs := Parse([]byte(`comment
+-- theirs-new.txt --
+t
+-- a.txt --
+1
+2
+3
+4
+five
+-- same.txt --
+same
+-- ours-del.txt --
+x
+-- conflict.txt --
+one
+deux
+three
+-- ours-new.txt --
+o
+`))
+	want := `comment
+-- a.txt --
+one
+2
+3
+4
+five
+-- same.txt --
+same
+-- conflict.txt --
+one
+<<<<<<< ours
+TWO
+=======
+deux
+>>>>>>> theirs
+three
+-- del-mod.txt --
+changed
+-- ours-new.txt --
+o
+-- theirs-new.txt --
+t
+`
+	merged, conflicts := Merge3(base, ours, theirs)
+	if got := string(Format(merged)); got != want {
+		t.Errorf("Merge3:\n%s\nwant:\n%s", got, want)
+	}
+	wantConflicts := []Conflict{{Name: "conflict.txt"}, {Name: "del-mod.txt"}}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("conflicts = %+v, want %+v", conflicts, wantConflicts)
+	}
+}
+
+func TestMerge3Lines(t *testing.T) {
+	tests := []struct {
+		name               string
+		base, ours, theirs string
+		want               string
+		clean              bool
+	}{
+		{"both same", "a\n", "b\n", "b\n", "b\n", true},
+		{"adjacent", "a\nb\nc\nd\n", "A\nb\nc\nd\n", "a\nb\nc\nD\n", "A\nb\nc\nD\n", true},
+		{"insertions", "a\nb\n", "a\nx\nb\n", "a\nb\ny\n", "a\nx\nb\ny\n", true},
+		{"same insertion point", "a\nb\n", "a\nx\nb\n", "a\ny\nb\n", "a\n<<<<<<< ours\nx\n=======\ny\n>>>>>>> theirs\nb\n", false},
+		{"no final newline", "a", "b", "c", "<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\n", false},
+		{"binary", "\x00a", "\x00b", "\x00c", "\x00b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, clean := mergeData([]byte(tt.base), []byte(tt.ours), []byte(tt.theirs))
+			if string(got) != tt.want || clean != tt.clean {
+				t.Errorf("mergeData = %q, %v; want %q, %v", got, clean, tt.want, tt.clean)
+			}
+		})
+	}
+}
+
+func TestMerge3Comment(t *testing.T) {
+	base := &Archive{Comment: []byte("a\n")}
+	merged, conflicts := Merge3(base, &Archive{Comment: []byte("b\n")}, &Archive{Comment: []byte("c\n")})
+	if len(conflicts) != 1 || conflicts[0].Name != "" {
+		t.Errorf("conflicts = %+v, want the comment", conflicts)
+	}
+	if want := "<<<<<<< ours\nb\n=======\nc\n>>>>>>> theirs\n"; string(merged.Comment) != want {
+		t.Errorf("comment = %q, want %q", merged.Comment, want)
+	}
+}
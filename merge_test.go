@@ -0,0 +1,129 @@
+package txtar
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	const (
+		dst = "team a\n-- a.txt --\na\n-- b.txt --\nb\n-- .env --\nx\n"
+		src = "team b\n-- b.txt --\nB\n-- a.txt --\na\n-- c.txt --\nc\n-- .env --\ny\n"
+	)
+	tests := []struct {
+		policy    MergePolicy
+		want      string
+		conflicts []Conflict
+	}{
+		{
+			policy:    KeepFirst,
+			want:      "team a\nteam b\n-- a.txt --\na\n-- b.txt --\nb\n-- .env --\nx\n-- c.txt --\nc\n",
+			conflicts: []Conflict{{Name: "b.txt"}, {Name: ".env"}},
+		},
+		{
+			policy:    KeepLast,
+			want:      "team a\nteam b\n-- a.txt --\na\n-- b.txt --\nB\n-- .env --\ny\n-- c.txt --\nc\n",
+			conflicts: []Conflict{{Name: "b.txt"}, {Name: ".env"}},
+		},
+		{
+			policy:    RenameWithSuffix,
+			want:      "team a\nteam b\n-- a.txt --\na\n-- b.txt --\nb\n-- .env --\nx\n-- b-1.txt --\nB\n-- c.txt --\nc\n-- .env-1 --\ny\n",
+			conflicts: []Conflict{{Name: "b.txt", Renamed: "b-1.txt"}, {Name: ".env", Renamed: ".env-1"}},
+		},
+		{
+			policy:    ErrorOnConflict,
+			want:      dst,
+			conflicts: []Conflict{{Name: "b.txt"}, {Name: ".env"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy.String(), func(t *testing.T) {
+			a := Parse([]byte(dst))
+			conflicts, err := Merge(a, Parse([]byte(src)), tt.policy)
+			if (err != nil) != (tt.policy == ErrorOnConflict) {
+				t.Errorf("Merge error = %v", err)
+			}
+			if err != nil && !errors.Is(err, ErrConflict) {
+				t.Errorf("Merge error = %v, want ErrConflict", err)
+			}
+			if got := string(Format(a)); got != tt.want {
+				t.Errorf("Merge result:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if !reflect.DeepEqual(conflicts, tt.conflicts) {
+				t.Errorf("conflicts = %+v, want %+v", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestMergeSourceDuplicates(t *testing.T) {
+	const (
+		dst = "-- a.txt --\na\n"
+		src = "-- b.txt --\n1\n-- b.txt --\n2\n-- a.txt --\na\n"
+	)
+	a := Parse([]byte(dst))
+	conflicts, err := Merge(a, Parse([]byte(src)), ErrorOnConflict)
+	if !errors.Is(err, ErrConflict) {
+		t.Errorf("Merge error = %v, want ErrConflict", err)
+	}
+	if want := []Conflict{{Name: "b.txt"}}; !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("conflicts = %+v, want %+v", conflicts, want)
+	}
+	if got := string(Format(a)); got != dst {
+		t.Errorf("dst changed:\n%s", got)
+	}
+
+	a = Parse([]byte(dst))
+	conflicts, err = Merge(a, Parse([]byte(src)), KeepLast)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Conflict{{Name: "b.txt"}}; !reflect.DeepEqual(conflicts, want) {
+		t.Errorf("KeepLast conflicts = %+v, want %+v", conflicts, want)
+	}
+	if got, want := string(Format(a)), "-- a.txt --\na\n-- b.txt --\n2\n"; got != want {
+		t.Errorf("KeepLast result:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMergeComments(t *testing.T) {
+	tests := []struct{ dst, src, want string }{
+		{"", "b\n", "b\n"},
+		{"a\n", "", "a\n"},
+		{"a\n", "a\n", "a\n"},
+		{"a", "b\n", "a\nb\n"},
+	}
+	for _, tt := range tests {
+		a := &Archive{Comment: []byte(tt.dst)}
+		if _, err := Merge(a, &Archive{Comment: []byte(tt.src)}, KeepFirst); err != nil {
+			t.Fatal(err)
+		}
+		if string(a.Comment) != tt.want {
+			t.Errorf("Merge(%q, %q) comment = %q, want %q", tt.dst, tt.src, a.Comment, tt.want)
+		}
+	}
+}
+
+func TestParseMergePolicy(t *testing.T) {
+	policies := map[string]MergePolicy{
+		"keep-first":         KeepFirst,
+		"keep-last":          KeepLast,
+		"error-on-conflict":  ErrorOnConflict,
+		"rename-with-suffix": RenameWithSuffix,
+	}
+	for name, p := range policies {
+		got, err := ParseMergePolicy(name)
+		if err != nil || got != p {
+			t.Errorf("ParseMergePolicy(%q) = %v, %v; want %v", name, got, err, p)
+		}
+		if p.String() != name {
+			t.Errorf("%v.String() = %q, want %q", p, p.String(), name)
+		}
+	}
+	for _, name := range []string{"newest", "error", "rename"} {
+		if _, err := ParseMergePolicy(name); err == nil {
+			t.Errorf("ParseMergePolicy(%q) succeeded", name)
+		}
+	}
+}
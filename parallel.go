@@ -0,0 +1,97 @@
+package txtar
+
+import (
+	"bytes"
+	"runtime"
+	"slices"
+	"sync"
+)
+
+// parallelMinChunk is the least data ParseParallel gives each worker.
+var parallelMinChunk = 256 << 10
+
+// ParseParallel is like Parse but scans data for file markers and decodes
+// the files using up to workers goroutines. If workers is zero or less,
+// it uses GOMAXPROCS. The result is the same as that of Parse(data).
+func ParseParallel(data []byte, workers int) *Archive {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(data)/parallelMinChunk)
+	if workers <= 1 {
+		return Parse(data)
+	}
+
+	// Find the file markers, each worker checking the lines that
+	// start in its part of data.
+	found := make([][]foundMarker, workers)
+	chunk := (len(data) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for w := range workers {
+		lo, hi := min(w*chunk, len(data)), min((w+1)*chunk, len(data))
+		wg.Go(func() { found[w] = scanMarkers(data, lo, hi) })
+	}
+	wg.Wait()
+	markers := slices.Concat(found...)
+
+	a := new(Archive)
+	if len(markers) == 0 {
+		a.Comment = FixNL(data)
+		return a
+	}
+	a.Comment = data[:markers[0].off:markers[0].off]
+	a.Files = make([]File, len(markers))
+	chunk = (len(markers) + workers - 1) / workers
+	for lo := 0; lo < len(markers); lo += chunk {
+		hi := min(lo+chunk, len(markers))
+		wg.Go(func() {
+			for i := lo; i < hi; i++ {
+				m := markers[i]
+				var raw []byte
+				if i+1 < len(markers) {
+					n := markers[i+1].off - (len(data) - len(m.after))
+					raw = m.after[:n:n]
+				} else {
+					raw = FixNL(m.after)
+				}
+				f := m.h.file()
+				f.Data = m.h.data(raw)
+				a.Files[i] = f
+			}
+		})
+	}
+	wg.Wait()
+	return a
+}
+
+// A foundMarker is a file marker line found by scanMarkers.
+type foundMarker struct {
+	off   int     // offset of the marker line in data
+	h     *header // the parsed marker line
+	after []byte  // data after the marker line, as returned by isMarker
+}
+
+// scanMarkers returns the file marker lines in data
+// that start at offsets in [lo, hi), in order.
+func scanMarkers(data []byte, lo, hi int) []foundMarker {
+	var found []foundMarker
+	check := func(off int) {
+		if h, after := isMarker(data[off:]); h != nil {
+			found = append(found, foundMarker{off, h, after})
+		}
+	}
+	if lo == 0 && hi > 0 {
+		check(0)
+	}
+	// Other markers follow a "\n-- " whose newline is in [lo-1, hi-1).
+	lo, end := max(lo-1, 0), min(hi-2+len(newlineMarker), len(data))
+	for lo < end {
+		i := bytes.Index(data[lo:end], newlineMarker)
+		if i < 0 {
+			break
+		}
+		check(lo + i + 1)
+		lo += i + 1
+	}
+	return found
+}
@@ -0,0 +1,53 @@
+package txtar
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseParallel(t *testing.T) {
+	defer func(n int) { parallelMinChunk = n }(parallelMinChunk)
+	parallelMinChunk = 1
+
+	pieces := []string{
+		"-- a --\n", "-- b --\r\n", "--  --\n", "-- \"q\\n\" --\n", "-- c (quoted) --\n",
+		">-- d --\n", "-- e (base64) --\n", "QUJD\n", "-- f -> a --\n", "-- g mode=0644 --\n",
+		"-- ", " --", "--", "-", "\n", "\r\n", "x", "text\n", "\x00",
+	}
+	r := rand.New(rand.NewPCG(1, 2))
+	for i := range 2000 {
+		var b strings.Builder
+		for range r.IntN(40) {
+			b.WriteString(pieces[r.IntN(len(pieces))])
+		}
+		data := []byte(b.String())
+		want := Parse(data)
+		for _, workers := range []int{2, 3, 7, 64} {
+			got := ParseParallel(data, workers)
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("input %d %q, %d workers:\ngot  %+v\nwant %+v", i, data, workers, got, want)
+			}
+			if cap(got.Comment) > len(got.Comment) && len(got.Files) > 0 {
+				t.Fatalf("input %d %q: comment capacity reaches into the first marker", i, data)
+			}
+		}
+	}
+}
+
+func BenchmarkParseParallel(b *testing.B) {
+	for _, size := range benchSizes {
+		for _, workers := range []int{1, 4, 16} {
+			b.Run(fmt.Sprintf("%dMB/workers=%d", size>>20, workers), func(b *testing.B) {
+				data := benchArchive(size)
+				b.SetBytes(int64(len(data)))
+				b.ResetTimer()
+				for range b.N {
+					ParseParallel(data, workers)
+				}
+			})
+		}
+	}
+}
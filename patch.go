@@ -0,0 +1,414 @@
+package txtar
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Problems reported by ApplyPatch, wrapped in a PatchError.
+var (
+	ErrPatchSyntax    = errors.New("malformed patch")
+	ErrHunkMismatch   = errors.New("hunk does not apply")
+	ErrPatchFileState = errors.New("file state does not match patch")
+	ErrBinaryPatch    = errors.New("binary patches are not supported")
+)
+
+// A PatchError describes why ApplyPatch rejected a patch.
+type PatchError struct {
+	Name string // name of the file being patched, if known
+	Line int    // line number in the patch
+	Err  error  // the problem, such as ErrHunkMismatch
+}
+
+func (e *PatchError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("patch line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("patch line %d: %s: %v", e.Line, e.Name, e.Err)
+}
+
+func (e *PatchError) Unwrap() error { return e.Err }
+
+// ApplyPatch applies the unified diff read from patch to the files of a.
+//
+// The patch may hold changes to several files, as written by diff -u or
+// git diff. File names have a leading a/ or b/ removed. A file whose old
+// name is /dev/null is created, and one whose new name is /dev/null is
+// deleted. Git's rename and mode headers are also understood.
+//
+// Each hunk must match the file exactly, though it may be found at a
+// different line than the one its header gives. If any hunk does not
+// apply, ApplyPatch returns a *PatchError and leaves a unchanged.
+func ApplyPatch(a *Archive, patch io.Reader) error {
+	files, err := parsePatch(patch)
+	if err != nil {
+		return err
+	}
+	b := &Archive{Files: slices.Clone(a.Files)}
+	for _, fp := range files {
+		if err := fp.apply(b); err != nil {
+			return err
+		}
+	}
+	a.Files = b.Files
+	return nil
+}
+
+// A filePatch is the part of a patch that changes one file.
+type filePatch struct {
+	line    int    // line number in the patch where it starts
+	oldName string // "" if the file is created
+	newName string // "" if the file is deleted
+	mode    fs.FileMode
+	setMode bool
+	hunks   []hunk
+}
+
+// A hunk is one change within a file.
+type hunk struct {
+	line     int // line number in the patch of the @@ header
+	oldStart int // line number in the old file, as given by the header
+	old, new []string
+}
+
+// name returns the name of the file to use in errors.
+func (fp *filePatch) name() string {
+	if fp.newName != "" {
+		return fp.newName
+	}
+	return fp.oldName
+}
+
+func (fp *filePatch) apply(a *Archive) error {
+	fail := func(line int, err error) error {
+		return &PatchError{Name: fp.name(), Line: line, Err: err}
+	}
+
+	var f File
+	if fp.oldName != "" {
+		var ok bool
+		if f, ok = a.Get(fp.oldName); !ok {
+			return fail(fp.line, fmt.Errorf("%w: %s does not exist", ErrPatchFileState, fp.oldName))
+		}
+	} else if a.Has(fp.newName) {
+		return fail(fp.line, fmt.Errorf("%w: %s already exists", ErrPatchFileState, fp.newName))
+	}
+
+	lines := splitLines(f.Data)
+	at := 0 // lines before at have already been patched
+	for _, h := range fp.hunks {
+		i, ok := h.find(lines, at)
+		if !ok {
+			return fail(h.line, ErrHunkMismatch)
+		}
+		lines = slices.Replace(lines, i, i+len(h.old), h.new...)
+		at = i + len(h.new)
+	}
+	data := []byte(strings.Join(lines, ""))
+
+	if fp.newName == "" {
+		if len(data) > 0 {
+			return fail(fp.line, fmt.Errorf("%w: deleted file %s is not empty after patching", ErrPatchFileState, fp.oldName))
+		}
+		a.Delete(fp.oldName)
+		return nil
+	}
+	if fp.oldName != "" && fp.oldName != fp.newName {
+		if a.Has(fp.newName) {
+			return fail(fp.line, fmt.Errorf("%w: %s already exists", ErrPatchFileState, fp.newName))
+		}
+		a.Delete(fp.oldName)
+	}
+	f.Name = fp.newName
+	f.Data = data
+	if fp.setMode {
+		f.Mode = fp.mode
+	}
+	a.SetFile(f)
+	return nil
+}
+
+// find returns where the old lines of h are in lines, searching outward
+// from the position given by the hunk header and not before at.
+func (h *hunk) find(lines []string, at int) (int, bool) {
+	want := h.oldStart - 1
+	if len(h.old) == 0 {
+		want = h.oldStart // an insertion after line oldStart
+	}
+	matches := func(i int) bool {
+		return i >= at && i+len(h.old) <= len(lines) && slices.Equal(lines[i:i+len(h.old)], h.old)
+	}
+	for d := 0; want-d >= at || want+d <= len(lines); d++ {
+		if matches(want - d) {
+			return want - d, true
+		}
+		if matches(want + d) {
+			return want + d, true
+		}
+	}
+	return 0, false
+}
+
+// A patchScanner reads a patch line by line.
+type patchScanner struct {
+	r    *bufio.Reader
+	line string
+	n    int // line number of line
+	err  error
+}
+
+// next reads the next line, keeping its newline.
+// It returns false at the end of the patch.
+func (s *patchScanner) next() bool {
+	if s.err != nil {
+		return false
+	}
+	s.line, s.err = s.r.ReadString('\n')
+	if s.err == io.EOF && s.line != "" {
+		s.err = nil
+	}
+	if s.err != nil {
+		return false
+	}
+	s.n++
+	return true
+}
+
+// parsePatch splits a patch into the changes to each file.
+func parsePatch(r io.Reader) ([]*filePatch, error) {
+	s := &patchScanner{r: bufio.NewReader(r)}
+	var (
+		files []*filePatch
+		cur   *filePatch
+		git   bool // cur started with a diff --git line
+		old   bool // cur has had a --- line
+	)
+	start := func() {
+		cur = &filePatch{line: s.n}
+		files = append(files, cur)
+		git, old = false, false
+	}
+	syntaxErr := func(format string, args ...any) error {
+		name := ""
+		if cur != nil {
+			name = cur.name()
+		}
+		return &PatchError{Name: name, Line: s.n, Err: fmt.Errorf("%w: "+format, append([]any{ErrPatchSyntax}, args...)...)}
+	}
+
+	for s.next() {
+		line := strings.TrimRight(s.line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			start()
+			git = true
+			oldName, newName, ok := parseGitHeader(line[len("diff --git "):])
+			if !ok {
+				return nil, syntaxErr("cannot parse %q", line)
+			}
+			cur.oldName, cur.newName = oldName, newName
+
+		case strings.HasPrefix(line, "--- "):
+			if cur == nil || old {
+				start()
+			}
+			old = true
+			cur.oldName = patchName(line[len("--- "):])
+
+		case strings.HasPrefix(line, "+++ "):
+			if cur == nil || !old {
+				return nil, syntaxErr("+++ line without --- line")
+			}
+			cur.newName = patchName(line[len("+++ "):])
+
+		case strings.HasPrefix(line, "@@ "):
+			if cur == nil || !old {
+				return nil, syntaxErr("hunk without file header")
+			}
+			h, err := parseHunk(s)
+			if err != nil {
+				return nil, syntaxErr("%v", err)
+			}
+			cur.hunks = append(cur.hunks, h)
+
+		case cur != nil && git:
+			if err := cur.parseGitExtended(line); errors.Is(err, ErrBinaryPatch) {
+				return nil, &PatchError{Name: cur.name(), Line: s.n, Err: err}
+			} else if err != nil {
+				return nil, syntaxErr("%v", err)
+			}
+		}
+	}
+	if s.err != io.EOF {
+		return nil, s.err
+	}
+	for _, fp := range files {
+		if fp.oldName == "" && fp.newName == "" {
+			return nil, &PatchError{Line: fp.line, Err: fmt.Errorf("%w: no file name", ErrPatchSyntax)}
+		}
+	}
+	return files, nil
+}
+
+// parseGitExtended parses a git extended header line such as "new file mode 100755".
+func (fp *filePatch) parseGitExtended(line string) error {
+	switch {
+	case strings.HasPrefix(line, "new file mode "):
+		fp.oldName = ""
+		return fp.parseMode(strings.TrimPrefix(line, "new file mode "), true)
+	case strings.HasPrefix(line, "deleted file mode "):
+		fp.newName = ""
+	case strings.HasPrefix(line, "new mode "):
+		return fp.parseMode(strings.TrimPrefix(line, "new mode "), false)
+	case strings.HasPrefix(line, "rename from "):
+		fp.oldName = unquoteOr(strings.TrimPrefix(line, "rename from "))
+	case strings.HasPrefix(line, "rename to "):
+		fp.newName = unquoteOr(strings.TrimPrefix(line, "rename to "))
+	case line == "GIT binary patch", strings.HasPrefix(line, "Binary files "):
+		return ErrBinaryPatch
+	}
+	return nil
+}
+
+// parseMode records a git file mode such as 100755.
+// The default mode for new files, 100644, is not recorded.
+func (fp *filePatch) parseMode(s string, isNew bool) error {
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || m&^0o777 != 0o100000 {
+		return fmt.Errorf("unsupported file mode %s", s)
+	}
+	if isNew && m == 0o100644 {
+		return nil
+	}
+	fp.mode, fp.setMode = fs.FileMode(m&0o777), true
+	return nil
+}
+
+// parseGitHeader parses the names in a "diff --git a/x b/y" line.
+func parseGitHeader(s string) (oldName, newName string, ok bool) {
+	if strings.HasPrefix(s, `"`) {
+		q, err := strconv.QuotedPrefix(s)
+		if err != nil {
+			return "", "", false
+		}
+		return patchName(q), patchName(strings.TrimPrefix(s[len(q):], " ")), true
+	}
+	// Unquoted names may contain spaces; assume both names are the same length.
+	if len(s)%2 == 1 {
+		half := len(s) / 2
+		if s[half] == ' ' {
+			oldName, newName = patchName(s[:half]), patchName(s[half+1:])
+			if oldName == newName {
+				return oldName, newName, true
+			}
+		}
+	}
+	before, after, found := strings.Cut(s, " b/")
+	if !found {
+		return "", "", false
+	}
+	return patchName(before), patchName("b/" + after), true
+}
+
+// patchName parses the file name in a ---, +++ or diff --git line,
+// returning "" for /dev/null.
+func patchName(s string) string {
+	if !strings.HasPrefix(s, `"`) {
+		// diff -u adds a tab and a time stamp after the name.
+		s, _, _ = strings.Cut(s, "\t")
+	}
+	name := unquoteOr(s)
+	if name == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(name, "a/") || strings.HasPrefix(name, "b/") {
+		name = name[2:]
+	}
+	return name
+}
+
+// parseHunk parses a hunk starting at the @@ line s is positioned at.
+func parseHunk(s *patchScanner) (hunk, error) {
+	h := hunk{line: s.n}
+	var oldLen, newLen int
+	var err error
+	fields := strings.Fields(s.line)
+	if len(fields) < 4 || fields[3] != "@@" {
+		return h, fmt.Errorf("bad hunk header %q", strings.TrimSpace(s.line))
+	}
+	if h.oldStart, oldLen, err = parseRange(fields[1], "-"); err != nil {
+		return h, err
+	}
+	if _, newLen, err = parseRange(fields[2], "+"); err != nil {
+		return h, err
+	}
+
+	// last holds the sides the previous line was added to,
+	// for a following "\ No newline at end of file" line.
+	var last []*[]string
+	noNewline := func() {
+		for _, side := range last {
+			l := *side
+			l[len(l)-1] = strings.TrimSuffix(l[len(l)-1], "\n")
+		}
+		last = nil
+	}
+	for len(h.old) < oldLen || len(h.new) < newLen {
+		if !s.next() {
+			return h, errors.New("hunk is truncated")
+		}
+		line := s.line
+		if line == "\n" || line == "\r\n" {
+			line = " " + line // some tools drop the space of empty context lines
+		}
+		switch line[0] {
+		case ' ':
+			h.old = append(h.old, line[1:])
+			h.new = append(h.new, line[1:])
+			last = []*[]string{&h.old, &h.new}
+		case '-':
+			h.old = append(h.old, line[1:])
+			last = []*[]string{&h.old}
+		case '+':
+			h.new = append(h.new, line[1:])
+			last = []*[]string{&h.new}
+		case '\\':
+			noNewline()
+		default:
+			return h, fmt.Errorf("unexpected line in hunk: %q", strings.TrimSpace(line))
+		}
+		if len(h.old) > oldLen || len(h.new) > newLen {
+			return h, errors.New("hunk is longer than its header says")
+		}
+	}
+	if peek, _ := s.r.Peek(1); len(peek) > 0 && peek[0] == '\\' {
+		s.next()
+		noNewline()
+	}
+	return h, nil
+}
+
+// parseRange parses one side of a hunk header, such as -1,5 or +3.
+func parseRange(s, sign string) (start, n int, err error) {
+	rest, ok := strings.CutPrefix(s, sign)
+	if !ok {
+		return 0, 0, fmt.Errorf("bad hunk range %q", s)
+	}
+	first, count, hasCount := strings.Cut(rest, ",")
+	if start, err = strconv.Atoi(first); err != nil {
+		return 0, 0, fmt.Errorf("bad hunk range %q", s)
+	}
+	n = 1
+	if hasCount {
+		if n, err = strconv.Atoi(count); err != nil {
+			return 0, 0, fmt.Errorf("bad hunk range %q", s)
+		}
+	}
+	return start, n, nil
+}
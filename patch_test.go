@@ -0,0 +1,190 @@
+package txtar
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const patchBase = `comment
+-- keep.txt --
+keep
+-- edit.txt --
+1
+2
+3
+4
+5
+6
+7
+8
+-- old.txt --
+moved
+-- gone.txt --
+bye
+`
+
+func TestApplyPatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name: "git diff",
+			patch: `diff --git a/edit.txt b/edit.txt
+index 1111111..2222222 100644
+--- a/edit.txt
++++ b/edit.txt
+@@ -1,3 +1,3 @@
+ 1
+-2
++two
+ 3
+@@ -6,3 +6,4 @@
+ 6
+ 7
+ 8
++9
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/new.sh b/new.sh
+new file mode 100755
+--- /dev/null
++++ b/new.sh
+@@ -0,0 +1,2 @@
++#!/bin/sh
++echo hi
+\ No newline at end of file
+diff --git a/old.txt b/dir/new.txt
+similarity index 100%
+rename from old.txt
+rename to dir/new.txt
+`,
+			want: `comment
+-- keep.txt --
+keep
+-- edit.txt --
+1
+two
+3
+4
+5
+6
+7
+8
+9
+-- new.sh mode=0755 --
+#!/bin/sh
+echo hi
+-- dir/new.txt --
+moved
+`,
+		},
+		{
+			name: "diff -u with offset",
+			patch: `--- edit.txt	2024-01-01 00:00:00
++++ edit.txt	2024-01-02 00:00:00
+@@ -10,2 +10,2 @@
+ 5
+-6
++six
+`,
+			want: strings.Replace(patchBase, "\n6\n", "\nsix\n", 1),
+		},
+		{
+			name: "create empty file",
+			patch: `diff --git a/empty b/empty
+new file mode 100644
+index 0000000..e69de29
+`,
+			want: patchBase + "-- empty --\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Parse([]byte(patchBase))
+			if err := ApplyPatch(a, strings.NewReader(tt.patch)); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(Format(a)); got != tt.want {
+				t.Errorf("patched archive:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		patch string
+		err   error
+		line  int
+	}{
+		{
+			name:  "mismatch",
+			patch: "--- a/keep.txt\n+++ b/keep.txt\n@@ -1 +1 @@\n-nope\n+yes\n",
+			err:   ErrHunkMismatch,
+			line:  3,
+		},
+		{
+			name:  "second file fails",
+			patch: "--- a/keep.txt\n+++ b/keep.txt\n@@ -1 +1 @@\n-keep\n+kept\n--- a/missing\n+++ b/missing\n@@ -1 +1 @@\n-a\n+b\n",
+			err:   ErrPatchFileState,
+			line:  6,
+		},
+		{
+			name:  "create existing",
+			patch: "--- /dev/null\n+++ b/keep.txt\n@@ -0,0 +1 @@\n+x\n",
+			err:   ErrPatchFileState,
+			line:  1,
+		},
+		{
+			name:  "truncated hunk",
+			patch: "--- a/keep.txt\n+++ b/keep.txt\n@@ -1,2 +1,2 @@\n-keep\n",
+			err:   ErrPatchSyntax,
+			line:  4,
+		},
+		{
+			name:  "binary",
+			patch: "diff --git a/x.png b/x.png\nBinary files a/x.png and b/x.png differ\n",
+			err:   ErrBinaryPatch,
+			line:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := Parse([]byte(patchBase))
+			err := ApplyPatch(a, strings.NewReader(tt.patch))
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ApplyPatch error = %v, want %v", err, tt.err)
+			}
+			var perr *PatchError
+			if !errors.As(err, &perr) || perr.Line != tt.line {
+				t.Errorf("ApplyPatch error = %#v, want line %d", err, tt.line)
+			}
+			if got := string(Format(a)); got != patchBase {
+				t.Errorf("archive changed by failed patch:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestDiffApplyPatch(t *testing.T) {
+	a := Parse([]byte(patchBase))
+	b := Parse([]byte(strings.Replace(patchBase, "\n4\n5\n", "\nfour\n5\n", 1) + "-- added --\nnew\n"))
+	var patch strings.Builder
+	for _, c := range Diff(a, b) {
+		patch.WriteString(c.Diff)
+	}
+	if err := ApplyPatch(a, strings.NewReader(patch.String())); err != nil {
+		t.Fatalf("ApplyPatch(Diff):\n%s\nerror: %v", patch.String(), err)
+	}
+	if got, want := string(Format(a)), string(Format(b)); got != want {
+		t.Errorf("patched archive:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,62 @@
+package txtar
+
+import "bytes"
+
+// A Position describes where a file entry is in a serialized archive.
+// Line numbers start at 1.
+type Position struct {
+	Offset     int64 // byte offset of the file marker line
+	Line       int   // line number of the file marker line
+	DataOffset int64 // byte offset of the first line of file data
+	DataLine   int   // line number of the first line of file data
+}
+
+// ParseWithPositions is like Parse but also returns the position
+// of each file in data. The positions are parallel to the returned
+// Archive's Files.
+func ParseWithPositions(data []byte) (*Archive, []Position) {
+	return parse(data, true)
+}
+
+// parse implements Parse, also recording positions if withPos is set.
+func parse(data []byte, withPos bool) (*Archive, []Position) {
+	var (
+		a         = new(Archive)
+		positions []Position
+		orig      = data
+		line      = 1 // line number at the start of data
+	)
+	// next returns the text before the next file marker in data
+	// and the parsed marker, advancing data past the marker line.
+	next := func() (before []byte, h *header) {
+		off := len(orig) - len(data)
+		before, h, data = findFileMarker(data)
+		if !withPos || h == nil {
+			return before, h
+		}
+		line += bytes.Count(before, []byte("\n"))
+		p := Position{
+			Offset:     int64(off + len(before)),
+			Line:       line,
+			DataOffset: int64(len(orig) - len(data)),
+		}
+		if orig[p.DataOffset-1] == '\n' {
+			line++
+		}
+		p.DataLine = line
+		positions = append(positions, p)
+		return before, h
+	}
+
+	var h *header
+	a.Comment, h = next()
+	for h != nil {
+		cur := h
+		var raw []byte
+		raw, h = next()
+		f := cur.file()
+		f.Data = cur.data(raw)
+		a.Files = append(a.Files, f)
+	}
+	return a, positions
+}
@@ -0,0 +1,84 @@
+package txtar
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPositions(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Position
+	}{
+		{
+			name: "basic",
+			text: "comment\n-- a --\nhello\nworld\n-- b --\nx\n",
+			want: []Position{
+				{Offset: 8, Line: 2, DataOffset: 16, DataLine: 3},
+				{Offset: 28, Line: 5, DataOffset: 36, DataLine: 6},
+			},
+		},
+		{
+			name: "no comment",
+			text: "-- a --\n-- b --\n",
+			want: []Position{
+				{Offset: 0, Line: 1, DataOffset: 8, DataLine: 2},
+				{Offset: 8, Line: 2, DataOffset: 16, DataLine: 3},
+			},
+		},
+		{
+			name: "extra spaces and crlf",
+			text: "c\r\n--  a  --\r\nx\r\n-- b --\r\n",
+			want: []Position{
+				{Offset: 3, Line: 2, DataOffset: 14, DataLine: 3},
+				{Offset: 17, Line: 4, DataOffset: 26, DataLine: 5},
+			},
+		},
+		{
+			name: "final marker without newline",
+			text: "-- a --\nx\n-- b --",
+			want: []Position{
+				{Offset: 0, Line: 1, DataOffset: 8, DataLine: 2},
+				{Offset: 10, Line: 3, DataOffset: 17, DataLine: 3},
+			},
+		},
+		{
+			name: "marker-like data",
+			text: "-- a (quoted) --\n>-- b --\n-- c --\n",
+			want: []Position{
+				{Offset: 0, Line: 1, DataOffset: 17, DataLine: 2},
+				{Offset: 26, Line: 3, DataOffset: 34, DataLine: 4},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, pos := ParseWithPositions([]byte(tt.text))
+			if !reflect.DeepEqual(pos, tt.want) {
+				t.Errorf("ParseWithPositions:\nhave %+v\nwant %+v", pos, tt.want)
+			}
+			if len(a.Files) != len(pos) {
+				t.Errorf("got %d files and %d positions", len(a.Files), len(pos))
+			}
+
+			r := NewReader(strings.NewReader(tt.text))
+			var rpos []Position
+			for {
+				_, err := r.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				rpos = append(rpos, r.Pos())
+			}
+			if !reflect.DeepEqual(rpos, tt.want) {
+				t.Errorf("Reader.Pos:\nhave %+v\nwant %+v", rpos, tt.want)
+			}
+		})
+	}
+}
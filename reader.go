@@ -2,9 +2,12 @@ package txtar
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"iter"
+	"strings"
 )
 
 // Reader provides sequential access to the contents of a txtar archive.
@@ -13,52 +16,148 @@ import (
 type Reader struct {
 	r             *bufio.Reader
 	atStartOfLine bool
-	nextFile      File
+	nextFile      *header
 	nextFileValid bool
 	filesStarted  bool
-	pending       []byte
+	cur           *header   // marker of the current file, or nil in the comment
+	body          io.Reader // decodes the data of the current file, if it is encoded
+	off           int64     // bytes consumed from r
+	lines         int       // newlines consumed from r
+	pos, nextPos  Position  // positions of the current and next file
+	opts          ReaderOptions
+	files         int    // files returned by Next
+	size          int64  // bytes of the comment or current file read from r
+	pending       []byte // a line read from r to be returned as data
+	err           error  // sticky limit or read error
+	ctx           context.Context
 }
 
-// NewReader creates a new Reader reading from r.
+// ReaderOptions sets limits on the archives a Reader accepts.
+// A zero field means no limit. Within its limits, a Reader
+// reads an archive exactly as Parse does.
+type ReaderOptions struct {
+	// MaxMarkerLen is the maximum length in bytes, including the line
+	// ending, of a line beginning with "-- ". The Reader has to hold such
+	// a line in memory to tell whether it is a file marker.
+	MaxMarkerLen int
+
+	// MaxFileSize is the maximum size in bytes of a file's data
+	// as stored in the archive, before decoding.
+	MaxFileSize int64
+
+	// MaxFiles is the maximum number of files in the archive.
+	MaxFiles int
+
+	// MaxCommentSize is the maximum size in bytes of the archive comment.
+	MaxCommentSize int64
+}
+
+// A LimitError reports that an archive exceeds a limit set in ReaderOptions.
+// Once a Reader returns a LimitError, it returns it from every later call.
+type LimitError struct {
+	Limit string // name of the ReaderOptions field, such as "MaxFileSize"
+	Max   int64  // value of the limit
+	Name  string // file being read, or "" for the comment or a marker line
+}
+
+func (e *LimitError) Error() string {
+	if e.Name != "" {
+		return fmt.Sprintf("txtar: %s: archive exceeds %s of %d", e.Name, e.Limit, e.Max)
+	}
+	return fmt.Sprintf("txtar: archive exceeds %s of %d", e.Limit, e.Max)
+}
+
+// NewReader creates a new Reader reading from r, with no limits.
 func NewReader(r io.Reader) *Reader {
+	return NewReaderWithOptions(r, ReaderOptions{})
+}
+
+// NewReaderWithOptions creates a new Reader reading from r
+// that enforces the limits in opts.
+func NewReaderWithOptions(r io.Reader, opts ReaderOptions) *Reader {
 	return &Reader{
-		r:             bufio.NewReader(r),
+		r:             bufio.NewReaderSize(r, readerBufferSize),
 		atStartOfLine: true,
+		opts:          opts,
 	}
 }
 
+// NewReaderContext is like NewReaderWithOptions, but the Reader stops
+// with ctx.Err() once ctx is done. The context is checked before each
+// read from the archive, so it also stops the iterators returned by
+// All and AllWithData; a read already blocked in r is not interrupted.
+func NewReaderContext(ctx context.Context, r io.Reader, opts ReaderOptions) *Reader {
+	tr := NewReaderWithOptions(r, opts)
+	tr.ctx = ctx
+	return tr
+}
+
 // Next advances to the next entry in the archive.
 // It returns the File header for the next file.
 // The Data field of the returned File is always nil.
 //
 // If there are no more files, Next returns io.EOF.
 func (r *Reader) Next() (File, error) {
+	if err := r.ctxErr(); err != nil {
+		return File{}, err
+	}
 	r.filesStarted = true
-	// If we have a pending next file (found during Read), return it.
-	if r.nextFileValid {
-		f := r.nextFile
-		r.nextFileValid = false
-		r.nextFile = File{}
-		return f, nil
+	r.body = nil
+	if !r.nextFileValid {
+		// Consume remaining data of current file
+		_, err := io.Copy(io.Discard, rawReader{r})
+		if err != nil {
+			return File{}, err
+		}
 	}
-
-	// Consume remaining data of current file
-	_, err := io.Copy(io.Discard, r)
-	if err != nil {
-		return File{}, err
+	if r.err != nil {
+		return File{}, r.err
 	}
 
-	// After consuming, check if we found the next file
+	// Check if we found the next file, either during Read or while consuming.
 	if r.nextFileValid {
-		f := r.nextFile
+		h := r.nextFile
 		r.nextFileValid = false
-		r.nextFile = File{}
-		return f, nil
+		r.nextFile = nil
+		if r.opts.MaxFiles > 0 && r.files >= r.opts.MaxFiles {
+			r.err = &LimitError{Limit: "MaxFiles", Max: int64(r.opts.MaxFiles), Name: h.name}
+			return File{}, r.err
+		}
+		r.files++
+		r.cur, r.pos, r.size = h, r.nextPos, 0
+		switch {
+		case h.link != "":
+			r.body = strings.NewReader(h.link)
+		case h.enc != plain:
+			r.body = h.enc.reader(rawReader{r})
+		}
+		return h.file(), nil
 	}
 
 	return File{}, io.EOF
 }
 
+// Pos returns the position in the archive of the file returned by
+// the last call to Next. Before the first call to Next,
+// it returns the zero Position.
+func (r *Reader) Pos() Position {
+	return r.pos
+}
+
+// ctxErr returns the error of r's context, if it has one and it is done.
+func (r *Reader) ctxErr() error {
+	if r.ctx == nil {
+		return nil
+	}
+	return r.ctx.Err()
+}
+
+// consume records that data has been read from r.r.
+func (r *Reader) consume(data []byte) {
+	r.off += int64(len(data))
+	r.lines += bytes.Count(data, []byte("\n"))
+}
+
 // ReadComment reads the archive comment from the stream.
 // It can only be called at the beginning of the stream, before the first call to Next.
 // If the comment has already been skipped or read, it returns an error.
@@ -71,87 +170,210 @@ func (r *Reader) ReadComment() ([]byte, error) {
 
 // Read reads from the current file in the archive.
 // It returns 0, io.EOF when the end of the file is reached.
+// The data of quoted and base64 encoded files is decoded as it is read,
+// and reading a symbolic link returns its target.
 func (r *Reader) Read(p []byte) (n int, err error) {
+	if r.body != nil {
+		if err := r.ctxErr(); err != nil {
+			return 0, err
+		}
+		return r.body.Read(p)
+	}
+	return r.readRaw(p)
+}
+
+// rawReader reads the data of the current file of a Reader as it is
+// stored in the archive, without decoding it.
+type rawReader struct{ r *Reader }
+
+func (rr rawReader) Read(p []byte) (int, error) { return rr.r.readRaw(p) }
+
+// readerBufferSize is the size of a Reader's buffer.
+// Lines that may be file markers and are longer than this
+// are read into memory separately.
+const readerBufferSize = 64 << 10
+
+// readRaw reads from the current file in the archive
+// as it is stored, stopping at the next file marker.
+// It copies as much buffered data as fits in p,
+// up to the start of a line that may be a file marker.
+func (r *Reader) readRaw(p []byte) (n int, err error) {
+	if err := r.ctxErr(); err != nil {
+		return 0, err
+	}
 	if r.nextFileValid {
 		return 0, io.EOF
 	}
+	if r.err != nil {
+		return 0, r.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	if r.atStartOfLine && len(r.pending) == 0 {
+		h, err := r.checkMarker()
+		if err != nil {
+			r.err = err
+			return 0, err
+		}
+		if h != nil {
+			r.nextFile = h
+			r.nextFileValid = true
+			return 0, io.EOF
+		}
+	}
+
+	if limit := r.maxSize(); limit > 0 {
+		if r.size >= limit {
+			if _, err := r.r.Peek(1); len(r.pending) == 0 && err != nil {
+				return 0, err
+			}
+			r.err = r.sizeError()
+			return 0, r.err
+		}
+		if int64(len(p)) > limit-r.size {
+			p = p[:limit-r.size]
+		}
+	}
 
 	if len(r.pending) > 0 {
 		n = copy(p, r.pending)
 		r.pending = r.pending[n:]
+		r.size += int64(n)
+		r.atStartOfLine = p[n-1] == '\n'
 		return n, nil
 	}
 
-	if r.atStartOfLine {
-		// Check for marker
-		peek, _ := r.r.Peek(3)
-		if string(peek) == "-- " {
-			// Potential marker.
-			// We need to read the whole line to verify.
-			line, err := r.r.ReadSlice('\n')
-
-			// Check if it's a marker.
-			// err == nil or EOF or ErrBufferFull
-			// if ErrBufferFull, it's a partial line. We assume not a marker if too long?
-			// But isMarker works on partial data if it doesn't have suffix.
-			// isMarker needs suffix " --".
-			// If buffer is full and we don't have \n, we might not have the suffix yet.
-			// If we assume standard buffer size (4096), lines longer than that starting with "-- " are not markers.
-
-			if err == nil || err == io.EOF {
-				name, _ := isMarker(line)
-				if name != "" {
-					r.nextFile = File{Name: name}
-					r.nextFileValid = true
-					r.atStartOfLine = true
-					return 0, io.EOF
-				}
+	buf, err := r.r.Peek(max(r.r.Buffered(), 1))
+	if len(buf) == 0 {
+		return 0, err
+	}
+
+	// Copy up to and including the first newline that may begin a marker.
+	if len(buf) > len(p)+len(marker) {
+		buf = buf[:len(p)+len(marker)]
+	}
+	end := len(buf)
+	if i := bytes.Index(buf, newlineMarker); i >= 0 {
+		end = i + 1
+	} else {
+		// A marker may start after a newline near the end of buf.
+		for i := max(len(buf)-len(marker), 0); i < len(buf); i++ {
+			if buf[i] == '\n' && bytes.HasPrefix(marker, buf[i+1:]) {
+				end = i + 1
+				break
 			}
+		}
+	}
+	n = copy(p, buf[:end])
+	r.consume(p[:n])
+	r.r.Discard(n)
+	r.size += int64(n)
+	r.atStartOfLine = p[n-1] == '\n'
+	return n, nil
+}
 
-			// Not a marker.
-			// Copy to pending.
-			r.pending = append([]byte(nil), line...)
+// maxSize returns the size limit for the comment or current file,
+// or 0 if there is none.
+func (r *Reader) maxSize() int64 {
+	if r.cur == nil {
+		return r.opts.MaxCommentSize
+	}
+	return r.opts.MaxFileSize
+}
 
-			if err == nil {
-				r.atStartOfLine = true
-			} else if err == bufio.ErrBufferFull {
-				r.atStartOfLine = false
-			} else if err == io.EOF {
-				r.atStartOfLine = false
-			}
+// sizeError returns the LimitError for exceeding maxSize.
+func (r *Reader) sizeError() error {
+	if r.cur == nil {
+		return &LimitError{Limit: "MaxCommentSize", Max: r.opts.MaxCommentSize}
+	}
+	return &LimitError{Limit: "MaxFileSize", Max: r.opts.MaxFileSize, Name: r.cur.name}
+}
 
-			// If error was not EOF/BufferFull, return it?
-			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
-				return 0, err
+// checkMarker checks whether the line at the start of the buffer is a
+// file marker. If so, it consumes the line, records its position and
+// returns the parsed marker. It returns an error only if the line is
+// longer than MaxMarkerLen; other read errors are left for the caller
+// to see when it reads the line as data.
+func (r *Reader) checkMarker() (*header, error) {
+	var line []byte
+	for size := len(marker); ; size = r.r.Buffered() + 1 {
+		buf, err := r.r.Peek(min(size, readerBufferSize))
+		if !bytes.HasPrefix(buf, marker) {
+			if bytes.HasPrefix(marker, buf) && err == nil {
+				continue // need more data
 			}
-
-			n = copy(p, r.pending)
-			r.pending = r.pending[n:]
-			return n, nil
+			return nil, nil
 		}
+		if i := bytes.IndexByte(buf, '\n'); i >= 0 {
+			line = buf[:i+1]
+			break
+		}
+		if err != nil {
+			line = buf // final line without a newline
+			break
+		}
+		if err := r.checkMarkerLen(len(buf) + 1); err != nil {
+			return nil, err
+		}
+		if len(buf) == readerBufferSize {
+			return r.checkLongMarker()
+		}
+	}
+	if err := r.checkMarkerLen(len(line)); err != nil {
+		return nil, err
 	}
+	h, _ := isMarker(line)
+	if h == nil {
+		return nil, nil
+	}
+	start := Position{Offset: r.off, Line: r.lines + 1}
+	r.consume(line)
+	r.r.Discard(len(line))
+	r.nextPos = start
+	r.nextPos.DataOffset, r.nextPos.DataLine = r.off, r.lines+1
+	return h, nil
+}
 
-	// Normal read until newline
-	line, err := r.r.ReadSlice('\n')
-	if len(line) > 0 {
-		n = copy(p, line)
-		if n < len(line) {
-			r.pending = append([]byte(nil), line[n:]...)
+// checkLongMarker is checkMarker for a line that does not fit in the buffer.
+// It reads the whole line, leaving it in r.pending to be read as data
+// if it is not a file marker.
+func (r *Reader) checkLongMarker() (*header, error) {
+	start := Position{Offset: r.off, Line: r.lines + 1}
+	var line []byte
+	for {
+		chunk, err := r.r.ReadSlice('\n')
+		line = append(line, chunk...)
+		r.consume(chunk)
+		if err := r.checkMarkerLen(len(line)); err != nil {
+			return nil, err
 		}
-
-		if err == nil {
-			r.atStartOfLine = true
-		} else if err == bufio.ErrBufferFull {
-			r.atStartOfLine = false
-			err = nil // Hide BufferFull
-		} else if err == io.EOF {
-			r.atStartOfLine = false
-			if len(r.pending) > 0 {
-				err = nil
-			}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
 		}
+		break
+	}
+	h, _ := isMarker(line)
+	if h == nil {
+		r.pending = line
+		return nil, nil
+	}
+	r.nextPos = start
+	r.nextPos.DataOffset, r.nextPos.DataLine = r.off, r.lines+1
+	return h, nil
+}
+
+// checkMarkerLen returns a LimitError if a line of n bytes
+// beginning with "-- " is longer than r accepts.
+func (r *Reader) checkMarkerLen(n int) error {
+	if limit := r.opts.MaxMarkerLen; limit > 0 && n > limit {
+		return &LimitError{Limit: "MaxMarkerLen", Max: int64(limit)}
 	}
-	return n, err
+	return nil
 }
 
 // All returns an iterator over the files in the archive.
@@ -0,0 +1,160 @@
+package txtar
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+var benchArchives sync.Map // size -> []byte
+
+// benchArchive returns a plain text archive of about size bytes,
+// made of 1 MiB files of 64-byte lines.
+func benchArchive(size int) []byte {
+	if data, ok := benchArchives.Load(size); ok {
+		return data.([]byte)
+	}
+	line := bytes.Repeat([]byte("abcdefgh"), 8)
+	line[len(line)-1] = '\n'
+	file := bytes.Repeat(line, (1<<20)/len(line))
+	var buf bytes.Buffer
+	buf.Grow(size + 1<<20)
+	buf.WriteString("benchmark archive\n")
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "-- file%d.txt --\n", i)
+		buf.Write(file)
+	}
+	data := buf.Bytes()
+	benchArchives.Store(size, data)
+	return data
+}
+
+var benchSizes = []int{16 << 20, 256 << 20}
+
+func BenchmarkReader(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			data := benchArchive(size)
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for range b.N {
+				r := NewReader(bytes.NewReader(data))
+				for {
+					if _, err := r.Next(); err == io.EOF {
+						break
+					} else if err != nil {
+						b.Fatal(err)
+					}
+					if _, err := io.Copy(io.Discard, r); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkLegacyReader(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			data := benchArchive(size)
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for range b.N {
+				r := &legacyReader{r: bufio.NewReader(bytes.NewReader(data)), atStartOfLine: true}
+				for r.next() {
+					if _, err := io.Copy(io.Discard, r); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkParseLarge(b *testing.B) {
+	for _, size := range benchSizes {
+		b.Run(fmt.Sprintf("%dMB", size>>20), func(b *testing.B) {
+			data := benchArchive(size)
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			for range b.N {
+				Parse(data)
+			}
+		})
+	}
+}
+
+// legacyReader is the line-at-a-time implementation of Reader.Read
+// that Reader replaced, kept to compare their speed.
+type legacyReader struct {
+	r             *bufio.Reader
+	atStartOfLine bool
+	nextFileValid bool
+	pending       []byte
+}
+
+// next skips to the next file, reporting whether there is one.
+func (r *legacyReader) next() bool {
+	if !r.nextFileValid {
+		io.Copy(io.Discard, r)
+	}
+	ok := r.nextFileValid
+	r.nextFileValid = false
+	return ok
+}
+
+func (r *legacyReader) Read(p []byte) (n int, err error) {
+	if r.nextFileValid {
+		return 0, io.EOF
+	}
+	if len(r.pending) > 0 {
+		n = copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+	if r.atStartOfLine {
+		peek, _ := r.r.Peek(3)
+		if string(peek) == "-- " {
+			line, err := r.r.ReadSlice('\n')
+			if err == nil || err == io.EOF {
+				if h, _ := isMarker(line); h != nil {
+					r.nextFileValid = true
+					r.atStartOfLine = true
+					return 0, io.EOF
+				}
+			}
+			r.pending = append([]byte(nil), line...)
+			r.atStartOfLine = err == nil
+			if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+				return 0, err
+			}
+			n = copy(p, r.pending)
+			r.pending = r.pending[n:]
+			return n, nil
+		}
+	}
+	line, err := r.r.ReadSlice('\n')
+	if len(line) > 0 {
+		n = copy(p, line)
+		if n < len(line) {
+			r.pending = append([]byte(nil), line[n:]...)
+		}
+		switch err {
+		case nil:
+			r.atStartOfLine = true
+		case bufio.ErrBufferFull:
+			r.atStartOfLine = false
+			err = nil
+		case io.EOF:
+			r.atStartOfLine = false
+			if len(r.pending) > 0 {
+				err = nil
+			}
+		}
+	}
+	return n, err
+}
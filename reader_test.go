@@ -2,10 +2,13 @@ package txtar
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
+	"errors"
 	"io"
 	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 //go:embed testdata/test.txtar
@@ -278,3 +281,156 @@ content2`
 		}
 	}
 }
+
+func TestReader_Chunking(t *testing.T) {
+	long := strings.Repeat("x", 5000)
+	huge := strings.Repeat("y", 3*readerBufferSize)
+	texts := []string{
+		testTxtar,
+		"comment\n-- a --\n1\n--\n-- \n-- b -\n-- b --\n2",
+		"-- a --\n\n\n-- b --\r\n-- c --",
+		"-- " + long + " --\n" + long + "\n-- next --\n-- -- --\n",
+		"-- a --\n-" + "- not -\n--",
+		"-- " + huge + " --\n" + huge + "\n-- " + huge + "\n-- b --\n-- " + huge,
+	}
+	readers := map[string]func(io.Reader) io.Reader{
+		"plain":   func(r io.Reader) io.Reader { return r },
+		"onebyte": iotest.OneByteReader,
+		"half":    iotest.HalfReader,
+		"dataerr": iotest.DataErrReader,
+	}
+	for i, text := range texts {
+		want := Parse([]byte(text))
+		for rname, wrap := range readers {
+			for _, size := range []int{1, 2, 7, 32 << 10} {
+				r := NewReader(wrap(strings.NewReader(text)))
+				comment, err := readAllSize(r, size)
+				if err != nil {
+					t.Fatal(err)
+				}
+				got := &Archive{Comment: FixNL(comment)}
+				for {
+					f, err := r.Next()
+					if err == io.EOF {
+						break
+					}
+					if err != nil {
+						t.Fatal(err)
+					}
+					if f.Data, err = readAllSize(r, size); err != nil {
+						t.Fatal(err)
+					}
+					f.Data = FixNL(f.Data)
+					got.Files = append(got.Files, f)
+				}
+				if !bytes.Equal(Format(got), Format(want)) {
+					t.Errorf("text %d, %s reader, size %d:\n%s\nwant:\n%s", i, rname, size, Format(got), Format(want))
+				}
+			}
+		}
+	}
+}
+
+func TestReaderOptions(t *testing.T) {
+	text := "comment\n-- a --\n12345\n-- b --\n1\n"
+	tests := []struct {
+		name string
+		opts ReaderOptions
+		want *LimitError // nil if the archive is within the limits
+	}{
+		{"none", ReaderOptions{}, nil},
+		{"all", ReaderOptions{MaxMarkerLen: 8, MaxFileSize: 6, MaxFiles: 2, MaxCommentSize: 8}, nil},
+		{"marker", ReaderOptions{MaxMarkerLen: 7}, &LimitError{Limit: "MaxMarkerLen", Max: 7}},
+		{"file size", ReaderOptions{MaxFileSize: 5}, &LimitError{Limit: "MaxFileSize", Max: 5, Name: "a"}},
+		{"files", ReaderOptions{MaxFiles: 1}, &LimitError{Limit: "MaxFiles", Max: 1, Name: "b"}},
+		{"comment", ReaderOptions{MaxCommentSize: 7}, &LimitError{Limit: "MaxCommentSize", Max: 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewReaderWithOptions(iotest.OneByteReader(strings.NewReader(text)), tt.opts)
+			got := new(Archive)
+			var err error
+			if got.Comment, err = io.ReadAll(r); err == nil {
+				for f, ferr := range r.AllWithData() {
+					if ferr != nil {
+						err = ferr
+						break
+					}
+					got.Files = append(got.Files, f)
+				}
+			}
+			if tt.want == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := Parse([]byte(text)); !bytes.Equal(Format(got), Format(want)) {
+					t.Errorf("got:\n%s\nwant:\n%s", Format(got), Format(want))
+				}
+				return
+			}
+			var le *LimitError
+			if !errors.As(err, &le) || *le != *tt.want {
+				t.Fatalf("err = %v, want %v", err, tt.want)
+			}
+			if _, err := r.Next(); err != error(le) {
+				t.Errorf("Next after limit: err = %v, want %v", err, le)
+			}
+		})
+	}
+}
+
+func TestReader_LongMarkerLimit(t *testing.T) {
+	name := strings.Repeat("n", 2*readerBufferSize)
+	text := "-- " + name + " --\ndata\n"
+	r := NewReader(strings.NewReader(text))
+	if f, err := r.Next(); err != nil || f.Name != name {
+		t.Fatalf("Next = %.20q, %v, want long name", f.Name, err)
+	}
+
+	r = NewReaderWithOptions(strings.NewReader(text), ReaderOptions{MaxMarkerLen: readerBufferSize + 10})
+	var le *LimitError
+	if _, err := r.Next(); !errors.As(err, &le) || le.Limit != "MaxMarkerLen" {
+		t.Fatalf("Next err = %v, want MaxMarkerLen LimitError", err)
+	}
+}
+
+// readAllSize reads r to EOF in reads of at most size bytes.
+func readAllSize(r io.Reader, size int) ([]byte, error) {
+	var data []byte
+	buf := make([]byte, size)
+	for {
+		n, err := r.Read(buf)
+		data = append(data, buf[:n]...)
+		if err == io.EOF {
+			return data, nil
+		}
+		if err != nil {
+			return data, err
+		}
+	}
+}
+
+func TestReaderContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := NewReaderContext(ctx, strings.NewReader(testTxtar), ReaderOptions{})
+	var names []string
+	var err error
+	for f, ferr := range r.AllWithData() {
+		if ferr != nil {
+			err = ferr
+			break
+		}
+		names = append(names, f.Name)
+		cancel()
+	}
+	if len(names) != 1 || err != context.Canceled {
+		t.Errorf("AllWithData after cancel read %q, err %v; want one file and context.Canceled", names, err)
+	}
+	if _, err := r.Next(); err != context.Canceled {
+		t.Errorf("Next after cancel: err = %v, want context.Canceled", err)
+	}
+	if _, err := r.Read(make([]byte, 10)); err != context.Canceled {
+		t.Errorf("Read after cancel: err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,88 @@
+package txtar
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Problems reported by Archive.Validate, wrapped in a ValidationError.
+var (
+	ErrEmptyName       = errors.New("empty file name")
+	ErrInvalidPath     = errors.New("invalid path")
+	ErrDuplicateName   = errors.New("duplicate file name")
+	ErrPathConflict    = errors.New("file name is also a directory")
+	ErrMarkerInComment = errors.New("comment contains a file marker line")
+)
+
+// A ValidationError describes a single problem found by Archive.Validate.
+type ValidationError struct {
+	Index int    // index into Archive.Files, or -1 for the comment
+	Name  string // name of the file, or "" for the comment
+	Err   error  // the problem, such as ErrDuplicateName
+}
+
+func (e *ValidationError) Error() string {
+	if e.Index < 0 {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("file %d %q: %v", e.Index, e.Name, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+// Validate checks that the archive can be written by Format and read back
+// unchanged, and used with FS. It returns nil if there are no problems,
+// and otherwise the errors.Join of a *ValidationError for each problem:
+//
+//   - ErrEmptyName for a file with an empty name,
+//   - ErrInvalidPath for a file name that fails fs.ValidPath,
+//   - ErrDuplicateName for each file after the first with a given name,
+//   - ErrPathConflict for a file whose name is also used as a directory
+//     by another file, and
+//   - ErrMarkerInComment if the comment contains a file marker line.
+//
+// File data containing file marker lines is not a problem, because Format
+// writes such files quoted.
+func (a *Archive) Validate() error {
+	var errs []error
+	report := func(i int, name string, err error) {
+		errs = append(errs, &ValidationError{Index: i, Name: name, Err: err})
+	}
+
+	if needsQuote(a.Comment) {
+		report(-1, "", ErrMarkerInComment)
+	}
+
+	seen := make(map[string]int)
+	for i, f := range a.Files {
+		switch {
+		case f.Name == "":
+			report(i, f.Name, ErrEmptyName)
+			continue
+		case !fs.ValidPath(f.Name):
+			report(i, f.Name, ErrInvalidPath)
+		}
+		if _, ok := seen[f.Name]; ok {
+			report(i, f.Name, ErrDuplicateName)
+			continue
+		}
+		seen[f.Name] = i
+	}
+
+	conflicts := make(map[int]bool)
+	for _, f := range a.Files {
+		if !fs.ValidPath(f.Name) {
+			continue
+		}
+		for dir := path.Dir(f.Name); dir != "."; dir = path.Dir(dir) {
+			if i, ok := seen[dir]; ok && !conflicts[i] {
+				conflicts[i] = true
+				report(i, dir, ErrPathConflict)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
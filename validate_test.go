@@ -0,0 +1,81 @@
+package txtar
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input *Archive
+		want  []ValidationError
+	}{
+		{
+			name: "valid",
+			input: &Archive{
+				Comment: []byte("comment\n"),
+				Files: []File{
+					{Name: "a.txt", Data: []byte("-- inner --\n")},
+					{Name: "dir/b.txt"},
+				},
+			},
+		},
+		{
+			name: "all problems",
+			input: &Archive{
+				Comment: []byte("comment\n-- file --\n"),
+				Files: []File{
+					{Name: ""},
+					{Name: "../escape"},
+					{Name: "dup"},
+					{Name: "dup"},
+					{Name: "dir"},
+					{Name: "dir/sub/file"},
+				},
+			},
+			want: []ValidationError{
+				{Index: -1, Err: ErrMarkerInComment},
+				{Index: 0, Name: "", Err: ErrEmptyName},
+				{Index: 1, Name: "../escape", Err: ErrInvalidPath},
+				{Index: 3, Name: "dup", Err: ErrDuplicateName},
+				{Index: 4, Name: "dir", Err: ErrPathConflict},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.input.Validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Validate() = nil, want error")
+			}
+			var got []ValidationError
+			for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+				var ve *ValidationError
+				if !errors.As(e, &ve) {
+					t.Fatalf("error %v is %T, want *ValidationError", e, e)
+				}
+				got = append(got, *ve)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Validate() = %v, want %d problems", err, len(tt.want))
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("problem %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			for _, want := range tt.want {
+				if !errors.Is(err, want.Err) {
+					t.Errorf("errors.Is(err, %v) = false", want.Err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package txtar
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"unicode/utf8"
+)
+
+var (
+	// ErrWriteAfterClose is returned when writing to a Writer after Close.
+	ErrWriteAfterClose = errors.New("txtar: write after close")
+
+	// ErrMarkerInData is returned by Writer.Write when the data of a file
+	// contains a line that would be read back as a file marker.
+	ErrMarkerInData = errors.New("txtar: file data contains a file marker line")
+
+	// ErrBinaryData is returned by Writer.Write when the data of a file
+	// begun as text is not valid UTF-8 or contains NUL bytes.
+	ErrBinaryData = errors.New("txtar: binary data in a text file")
+
+	// ErrCommentAfterFile is returned by Writer.WriteComment
+	// once a file has been started.
+	ErrCommentAfterFile = errors.New("txtar: comment written after first file")
+
+	errNoData = errors.New("txtar: current file does not take data")
+)
+
+// maxHeldLine is the most of a line starting with "-- " that Writer.Write
+// holds back while it may still turn out to be a file marker.
+const maxHeldLine = 64 << 10
+
+// A Writer provides sequential writing of a txtar archive.
+// Writer.WriteHeader (or Writer.CreateFile) begins a new file,
+// and Writer.Write supplies its data. Data written before the first
+// file is the archive comment.
+//
+// Data of a text file is written as it is, so a line in it that looks
+// like a file marker is an error, as is binary data. WriteHeader can
+// instead begin a quoted or base64 encoded file, and Writer.WriteFile
+// writes a complete file, quoting or encoding it as Format does.
+//
+// Writer does not buffer its output; wrap slow writers in a bufio.Writer.
+type Writer struct {
+	w             io.Writer
+	started       bool           // a file has been started
+	noData        bool           // the current file takes no more data
+	enc           encoding       // encoding of the current file's data
+	atStartOfLine bool           // the next byte written starts a line
+	line          []byte         // start of a line that may be a file marker or need quoting
+	long          bool           // line outgrew maxHeldLine; it holds only the line's end
+	partial       []byte         // incomplete UTF-8 sequence ending a text file's data
+	b64           io.WriteCloser // encoder for the data of a base64 file
+	wrote         bool           // data has been written for the current file or comment
+	last          byte           // last byte of data written
+	closed        bool
+	err           error // sticky error
+	ctx           context.Context
+}
+
+// NewWriter creates a new Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w, atStartOfLine: true}
+}
+
+// NewWriterContext is like NewWriter, but the Writer stops with ctx.Err()
+// once ctx is done. The context is checked before each write to w.
+func NewWriterContext(ctx context.Context, w io.Writer) *Writer {
+	return &Writer{w: w, atStartOfLine: true, ctx: ctx}
+}
+
+// WriteComment writes the archive comment.
+// It must be called before the first file is started.
+func (w *Writer) WriteComment(comment []byte) error {
+	if w.started {
+		return ErrCommentAfterFile
+	}
+	_, err := w.Write(comment)
+	return err
+}
+
+// WriteHeader finishes the current file, if any, and begins a new one
+// described by f. The data of the file is then written with Write and
+// stored the way Format would store f.Data: base64 encoded if it is
+// binary, quoted if it has lines that look like file markers, and as
+// is otherwise. f.Data itself is not written, so it only needs to be
+// like the data to come. A symbolic link takes its target from f.Data
+// and no data.
+func (w *Writer) WriteHeader(f File) error {
+	if err := w.finish(); err != nil {
+		return err
+	}
+	h := headerFor(f)
+	w.writeRaw([]byte(h.line()))
+	w.started, w.noData, w.enc = true, h.link != "", h.enc
+	w.wrote, w.last = false, 0
+	if w.enc == base64Encoded {
+		w.b64 = base64.NewEncoder(base64.StdEncoding, &base64LineWriter{w: w})
+	}
+	return w.err
+}
+
+// CreateFile begins a new text file with the given name, as WriteHeader
+// does, and returns a writer for its data.
+func (w *Writer) CreateFile(name string) (io.Writer, error) {
+	if err := w.WriteHeader(File{Name: name}); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// WriteFile finishes the current file, if any, and writes f in full,
+// quoting or encoding its data if needed as Format does.
+// No more data can be written until the next file is begun.
+func (w *Writer) WriteFile(f File) error {
+	if err := w.finish(); err != nil {
+		return err
+	}
+	h := headerFor(f)
+	data := h.encode(f.Data)
+	w.writeRaw([]byte(h.line()))
+	w.writeRaw(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		w.writeRaw([]byte{'\n'})
+	}
+	w.started, w.noData, w.enc = true, true, plain
+	w.wrote, w.last = false, 0
+	return w.err
+}
+
+// Write writes to the current file, or to the comment if no file has
+// been begun. It returns ErrMarkerInData, or ErrMarkerInComment,
+// if the data of a text file or comment contains a line that would be
+// read as a file marker, and ErrBinaryData if that of a text file is
+// binary. These may only be detected once the line or file is complete,
+// so the error can be returned by a later call, or by WriteHeader,
+// WriteFile or Close, and the archive written so far is not valid.
+// Only the start of a line beginning with "-- " is held back until the
+// line is complete; so much of a longer one is written that it is
+// reported as a marker if it ends like one.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriteAfterClose
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.noData {
+		return 0, errNoData
+	}
+	switch w.enc {
+	case base64Encoded:
+		n, err := w.b64.Write(p)
+		if err == nil {
+			err = w.err
+		}
+		return n, err
+	case quoted:
+		return w.writeQuoted(p)
+	}
+	if w.started && !w.checkText(p) {
+		w.err = ErrBinaryData
+		return 0, w.err
+	}
+	n := 0
+	for len(p) > 0 && w.err == nil {
+		i := bytes.IndexByte(p, '\n')
+		chunk := p
+		if i >= 0 {
+			chunk = p[:i+1]
+		}
+		if len(w.line) > 0 || w.long || (w.atStartOfLine && chunk[0] == marker[0]) {
+			w.holdLine(chunk, i >= 0)
+		} else {
+			w.writeData(chunk)
+		}
+		w.atStartOfLine = i >= 0
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, w.err
+}
+
+// holdLine adds chunk, which is part of a line that may be a file marker,
+// to the held-back line, writing what is known not to be a marker.
+// eol reports whether chunk ends the line.
+func (w *Writer) holdLine(chunk []byte, eol bool) {
+	if !w.long {
+		n := min(len(chunk), max(len(marker)-len(w.line), 0))
+		w.line = append(w.line, chunk[:n]...)
+		chunk = chunk[n:]
+		if !bytes.HasPrefix(w.line, marker) {
+			if !eol && bytes.HasPrefix(marker, w.line) {
+				return // too short to tell yet
+			}
+			w.writeData(w.line)
+			w.writeData(chunk)
+			w.line = w.line[:0]
+			return
+		}
+	}
+	// The line starts with "-- ". Hold back all of it, or,
+	// once it is too long, just enough of its end to tell
+	// whether it ends in " --".
+	keep := len(markerEnd) + len("\r\n")
+	switch {
+	case !w.long && len(w.line)+len(chunk) <= maxHeldLine:
+		w.line = append(w.line, chunk...)
+	case len(chunk) >= keep:
+		w.writeData(w.line)
+		w.writeData(chunk[:len(chunk)-keep])
+		w.line = append(w.line[:0], chunk[len(chunk)-keep:]...)
+		w.long = true
+	default:
+		w.line = append(w.line, chunk...)
+		n := len(w.line) - keep
+		w.writeData(w.line[:n])
+		w.line = w.line[:copy(w.line, w.line[n:])]
+		w.long = true
+	}
+	if eol {
+		w.flushLine()
+	}
+}
+
+// flushLine writes the held-back line, unless it is a file marker.
+func (w *Writer) flushLine() {
+	if w.isMarkerLine() {
+		w.err = ErrMarkerInData
+		if !w.started {
+			w.err = ErrMarkerInComment
+		}
+		return
+	}
+	w.writeData(w.line)
+	w.line = w.line[:0]
+	w.long = false
+}
+
+// isMarkerLine reports whether the held-back line is a file marker.
+// A line too long to hold back counts as one if it ends like one.
+func (w *Writer) isMarkerLine() bool {
+	if !w.long {
+		h, _ := isMarker(w.line)
+		return h != nil
+	}
+	end := bytes.TrimSuffix(w.line, []byte("\n"))
+	end = bytes.TrimSuffix(end, []byte("\r"))
+	return bytes.HasSuffix(end, markerEnd)
+}
+
+// checkText reports whether p, following the data already written
+// to the current file, may still be text: valid UTF-8 without NUL bytes.
+// A UTF-8 sequence left incomplete at the end of p is kept in w.partial.
+func (w *Writer) checkText(p []byte) bool {
+	if bytes.IndexByte(p, 0) >= 0 {
+		return false
+	}
+	for len(w.partial) > 0 && len(p) > 0 && !utf8.FullRune(w.partial) {
+		w.partial, p = append(w.partial, p[0]), p[1:]
+	}
+	if len(w.partial) > 0 {
+		if !utf8.FullRune(w.partial) {
+			return true
+		}
+		if !utf8.Valid(w.partial) {
+			return false
+		}
+		w.partial = w.partial[:0]
+	}
+	end := len(p)
+	for i := len(p) - 1; i >= 0 && i >= len(p)-(utf8.UTFMax-1); i-- {
+		if utf8.RuneStart(p[i]) {
+			if !utf8.FullRune(p[i:]) {
+				end = i
+			}
+			break
+		}
+	}
+	w.partial = append(w.partial, p[end:]...)
+	return utf8.Valid(p[:end])
+}
+
+// writeQuoted writes p to the current file, which is quoted:
+// a line that starts with zero or more '>' followed by "-- "
+// gets an extra '>', as quote adds. The '>'s are written as they
+// come, so only a partial "-- " after them is ever held back.
+func (w *Writer) writeQuoted(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && w.err == nil {
+		if w.atStartOfLine {
+			switch c := p[0]; {
+			case len(w.line) == 0 && c == '>':
+				w.writeData(p[:1])
+				p = p[1:]
+				continue
+			case c == marker[len(w.line)]:
+				w.line = append(w.line, c)
+				p = p[1:]
+				if len(w.line) == len(marker) {
+					w.writeData([]byte{'>'})
+					w.flushQuoted()
+				}
+				continue
+			}
+			w.flushQuoted()
+		}
+		i := bytes.IndexByte(p, '\n')
+		chunk := p
+		if i >= 0 {
+			chunk = p[:i+1]
+		}
+		w.writeData(chunk)
+		w.atStartOfLine = i >= 0
+		p = p[len(chunk):]
+	}
+	return n - len(p), w.err
+}
+
+// flushQuoted writes the held-back start of a line of a quoted file.
+func (w *Writer) flushQuoted() {
+	w.writeData(w.line)
+	w.line = w.line[:0]
+	w.atStartOfLine = false
+}
+
+// A base64LineWriter writes the output of a base64 encoder
+// to a Writer, breaking it into lines of base64LineLen characters.
+type base64LineWriter struct {
+	w   *Writer
+	col int // characters on the current line
+}
+
+func (b *base64LineWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 && b.w.err == nil {
+		if b.col == base64LineLen {
+			b.w.writeData([]byte{'\n'})
+			b.col = 0
+		}
+		k := min(len(p), base64LineLen-b.col)
+		b.w.writeData(p[:k])
+		b.col += k
+		p = p[k:]
+	}
+	return n - len(p), b.w.err
+}
+
+// writeData writes data of the current file or comment.
+func (w *Writer) writeData(data []byte) {
+	if len(data) > 0 {
+		w.writeRaw(data)
+		w.wrote, w.last = true, data[len(data)-1]
+	}
+}
+
+// writeRaw writes b to the underlying writer.
+func (w *Writer) writeRaw(b []byte) {
+	if w.err == nil && w.ctx != nil {
+		w.err = w.ctx.Err()
+	}
+	if w.err == nil {
+		_, w.err = w.w.Write(b)
+	}
+}
+
+// finish completes the current file or comment, adding a final newline if needed.
+func (w *Writer) finish() error {
+	if w.closed {
+		return ErrWriteAfterClose
+	}
+	if w.err != nil {
+		return w.err
+	}
+	switch {
+	case w.b64 != nil:
+		if err := w.b64.Close(); w.err == nil {
+			w.err = err
+		}
+		w.b64 = nil
+	case w.enc == quoted:
+		w.flushQuoted()
+	case len(w.line) > 0 || w.long:
+		w.flushLine()
+	}
+	if len(w.partial) > 0 && w.err == nil {
+		w.err = ErrBinaryData
+	}
+	if w.wrote && w.last != '\n' {
+		w.writeRaw([]byte{'\n'})
+	}
+	w.wrote, w.atStartOfLine = false, true
+	w.partial = w.partial[:0]
+	return w.err
+}
+
+// Close finishes the current file, adding a final newline if it lacks one.
+// It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	err := w.finish()
+	w.closed = true
+	return err
+}
@@ -0,0 +1,244 @@
+package txtar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.WriteComment([]byte("comment")); err != nil {
+		t.Fatal(err)
+	}
+	fw, err := w.CreateFile("a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Write in pieces that split lines, including ones starting with "-".
+	for _, s := range []string{"-", "- not a marker\n", "--", "x\nlast"} {
+		if _, err := fw.Write([]byte(s)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.WriteHeader(File{Name: "run.sh", Mode: 0o755}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("echo hi\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteFile(File{Name: "nested.txtar", Data: []byte("-- inner --\n")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteHeader(File{Name: "link", Data: []byte("a.txt"), Mode: fs.ModeSymlink}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err == nil {
+		t.Errorf("Write to symbolic link succeeded")
+	}
+	if _, err := w.CreateFile("empty"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("x")); err != ErrWriteAfterClose {
+		t.Errorf("Write after Close: %v, want ErrWriteAfterClose", err)
+	}
+
+	want := "comment\n" +
+		"-- a.txt --\n-- not a marker\n--x\nlast\n" +
+		"-- run.sh mode=0755 --\necho hi\n" +
+		"-- nested.txtar (quoted) --\n>-- inner --\n" +
+		"-- link -> a.txt --\n" +
+		"-- empty --\n"
+	if buf.String() != want {
+		t.Errorf("Writer output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriterMarkers(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		err    error
+	}{
+		{"marker", []string{"x\n-- b --\ny\n"}, ErrMarkerInData},
+		{"split marker", []string{"x\n-- ", "b -", "-\n"}, ErrMarkerInData},
+		{"final marker", []string{"-- b --"}, ErrMarkerInData},
+		{"crlf marker", []string{"-- b --\r\n"}, ErrMarkerInData},
+		{"not a marker", []string{"-- b -\n-- --\n"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			fw, _ := w.CreateFile("a")
+			var err error
+			for _, c := range tt.chunks {
+				if _, err = fw.Write([]byte(c)); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = w.Close()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+			if err == nil && len(Parse(buf.Bytes()).Files) != 1 {
+				t.Errorf("output has more than one file:\n%s", buf.String())
+			}
+		})
+	}
+
+	w := NewWriter(new(bytes.Buffer))
+	if err := w.WriteComment([]byte("-- x --\n")); err != ErrMarkerInComment {
+		t.Errorf("WriteComment with marker: %v, want ErrMarkerInComment", err)
+	}
+	w = NewWriter(new(bytes.Buffer))
+	w.CreateFile("a")
+	if err := w.WriteComment([]byte("c")); err != ErrCommentAfterFile {
+		t.Errorf("WriteComment after file: %v, want ErrCommentAfterFile", err)
+	}
+}
+
+func TestWriterMatchesFormat(t *testing.T) {
+	a := Parse([]byte("comment\n-- a --\n1\n-- b --\n-- c (quoted) --\n>-- d --\n-- e (base64) --\nAA==\n"))
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.WriteComment(a.Comment)
+	for _, f := range a.Files {
+		if err := w.WriteFile(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := Format(a); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Writer output:\n%s\nFormat output:\n%s", buf.Bytes(), want)
+	}
+}
+
+func TestWriterContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var buf bytes.Buffer
+	w := NewWriterContext(ctx, &buf)
+	if err := w.WriteFile(File{Name: "a", Data: []byte("a\n")}); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := w.WriteFile(File{Name: "b", Data: []byte("b\n")}); err != context.Canceled {
+		t.Errorf("WriteFile after cancel: err = %v, want context.Canceled", err)
+	}
+	if _, err := w.Write([]byte("more\n")); err != context.Canceled {
+		t.Errorf("Write after cancel: err = %v, want context.Canceled", err)
+	}
+	if got, want := buf.String(), "-- a --\na\n"; got != want {
+		t.Errorf("wrote %q, want %q", got, want)
+	}
+}
+
+func TestWriterHeaderEncoding(t *testing.T) {
+	binary := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR" + strings.Repeat("\x00\xff", 100))
+	text := []byte(">-- a --\n-- b --\n>>x\n--\n-\n--x\nlast -- ")
+	a := &Archive{Files: []File{
+		{Name: "logo.png", Data: binary},
+		{Name: "nested.txtar", Data: text},
+		{Name: "plain.txt", Data: []byte("plain\n")},
+	}}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for _, f := range a.Files {
+		if err := w.WriteHeader(f); err != nil {
+			t.Fatal(err)
+		}
+		// Write a byte at a time to split encoded lines and markers.
+		for i := range f.Data {
+			if _, err := w.Write(f.Data[i : i+1]); err != nil {
+				t.Fatalf("%s: %v", f.Name, err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if want := Format(a); !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("Writer output:\n%s\nFormat output:\n%s", buf.Bytes(), want)
+	}
+}
+
+func TestWriterBinaryData(t *testing.T) {
+	tests := []struct {
+		name   string
+		chunks []string
+		err    error
+	}{
+		{"nul", []string{"a\x00b\n"}, ErrBinaryData},
+		{"invalid utf8", []string{"bad \xc3\x28\n"}, ErrBinaryData},
+		{"split rune", []string{"caf\xc3", "\xa9\n"}, nil},
+		{"split 4-byte rune", []string{"\xf0\x9f", "\x98", "\x80\n"}, nil},
+		{"incomplete rune at end", []string{"caf\xc3"}, ErrBinaryData},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWriter(new(bytes.Buffer))
+			fw, _ := w.CreateFile("a")
+			var err error
+			for _, c := range tt.chunks {
+				if _, err = fw.Write([]byte(c)); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				err = w.Close()
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestWriterLongLine(t *testing.T) {
+	long := "-- " + strings.Repeat("x", 4*maxHeldLine)
+	for _, tt := range []struct {
+		end string
+		err error
+	}{
+		{"\n", nil},
+		{" -\n", nil},
+		{" --\n", ErrMarkerInData},
+		{" --\r\n", ErrMarkerInData},
+		{" --", ErrMarkerInData},
+	} {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		fw, _ := w.CreateFile("a")
+		data := long + tt.end
+		var err error
+		for i := 0; i < len(data) && err == nil; i += 1000 {
+			_, err = fw.Write([]byte(data[i:min(i+1000, len(data))]))
+			if cap(w.line) > 2*maxHeldLine {
+				t.Fatalf("held back %d bytes", cap(w.line))
+			}
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("line ending %q: error = %v, want %v", tt.end, err, tt.err)
+		}
+		if err == nil {
+			if got, want := buf.String(), "-- a --\n"+string(FixNL([]byte(data))); got != want {
+				t.Errorf("line ending %q: wrote %d bytes, want %d", tt.end, len(got), len(want))
+			}
+		}
+	}
+}